@@ -0,0 +1,154 @@
+package qos
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/BurntSushi/toml"
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Config is the `[qos]` section of the CRI-O TOML configuration file.
+type Config struct {
+	// Providers are the statically declared QoS resource providers, keyed
+	// by the CRI resource name they serve.
+	Providers map[string]*ProviderConfig `toml:"provider"`
+}
+
+// ProviderConfig declares a single QoS resource provider in the CRI-O
+// config file. Providers declared this way only validate and record the
+// requested class; they do not mutate the generated OCI spec. Subsystems
+// that need to do more (RDT, BlockIO, the CPU provider, ...) register a
+// Provider implementation directly with the Registry instead.
+type ProviderConfig struct {
+	// Classes is the list of class names valid for this provider.
+	Classes []string `toml:"classes"`
+	// Mutable marks whether the class may be changed on a running
+	// container via UpdateContainerResources.
+	Mutable bool `toml:"mutable"`
+	// Scope is "pod" or "container", reporting this resource to the
+	// kubelet at the matching level. Defaults to "container" if empty.
+	Scope string `toml:"scope"`
+}
+
+// staticProvider implements Provider for a provider declared entirely in
+// the config file, with no custom Apply behavior.
+type staticProvider struct {
+	name    string
+	classes []string
+	mutable bool
+	scope   Scope
+}
+
+func (s *staticProvider) Name() string      { return s.name }
+func (s *staticProvider) Scope() Scope      { return s.scope }
+func (s *staticProvider) Classes() []string { return s.classes }
+func (s *staticProvider) Mutable() bool     { return s.mutable }
+
+func (s *staticProvider) Apply(spec *rspec.Spec, containerID, class string) error {
+	logrus.Infof("setting QoS resource %s=%s for container %s", s.name, class, containerID)
+	return nil
+}
+
+// Reassign implements Reassigner, so a config-declared provider marked
+// `mutable = true` can actually back a kubelet UpdateContainerResources
+// call instead of advertising mutability it can't honor. Like Apply, it
+// does not reach any cgroup/resctrl state of its own - a resource that
+// needs to (RDT, BlockIO, the CPU provider) registers a Provider with its
+// own Reassign directly, rather than being declared in the config file.
+func (s *staticProvider) Reassign(containerID, class string) error {
+	logrus.Infof("reassigning QoS resource %s=%s for container %s", s.name, class, containerID)
+	return nil
+}
+
+// LoadConfig parses the TOML file at path and validates the declared
+// providers. It does not register them; Reload does that, replacing any
+// previously config-declared providers while leaving providers registered
+// directly via RegisterProvider (e.g. by the RDT or BlockIO subsystems)
+// untouched.
+func (r *Registry) LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		return nil, fmt.Errorf("decode QoS provider config %s: %w", path, err)
+	}
+
+	for name, pc := range cfg.Providers {
+		if len(pc.Classes) == 0 {
+			return nil, fmt.Errorf("QoS resource provider %q declares no classes", name)
+		}
+		switch Scope(pc.Scope) {
+		case "", ScopeContainer, ScopePod:
+		default:
+			return nil, fmt.Errorf("QoS resource provider %q: invalid scope %q", name, pc.Scope)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Reload re-reads path and swaps in the newly declared static providers.
+// It is safe to call concurrently with Apply/Validate/ResourceInfos. Call
+// it from WatchSIGHUP, or directly on whatever signal/trigger CRI-O
+// wants to hot-reload QoS providers on.
+func (r *Registry) Reload(path string) error {
+	cfg, err := r.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	for name, p := range r.providers {
+		if _, ok := p.(*staticProvider); ok {
+			delete(r.providers, name)
+		}
+	}
+	r.mu.Unlock()
+
+	for name, pc := range cfg.Providers {
+		scope := Scope(pc.Scope)
+		if scope == "" {
+			scope = ScopeContainer
+		}
+		if err := r.RegisterProvider(&staticProvider{
+			name:    name,
+			classes: pc.Classes,
+			mutable: pc.Mutable,
+			scope:   scope,
+		}); err != nil {
+			return err
+		}
+	}
+
+	logrus.Infof("Reloaded QoS resource provider config from %s (%d providers)", path, len(cfg.Providers))
+	return nil
+}
+
+// WatchSIGHUP starts a goroutine that calls Reload(path) every time the
+// process receives SIGHUP, so operators can add/change statically
+// declared providers without restarting CRI-O. It must be called once
+// during CRI-O startup, after the initial Reload(path); the returned
+// stop function ends the watch and should be called on shutdown.
+func (r *Registry) WatchSIGHUP(path string) (stop func()) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-sig:
+				if err := r.Reload(path); err != nil {
+					logrus.Errorf("Reloading QoS resource provider config from %s: %v", path, err)
+				}
+			case <-done:
+				signal.Stop(sig)
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
@@ -0,0 +1,236 @@
+package cpu
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultStatePath is where the allocator persists its state so it can
+// reconcile across a CRI-O restart.
+const DefaultStatePath = "/var/lib/crio/qos-cpu.json"
+
+// Allocation records the cpuset handed out to a single container.
+type Allocation struct {
+	Class     string `json:"class"`
+	CPUs      []int  `json:"cpus"`
+	Mems      []int  `json:"mems"`
+	Exclusive bool   `json:"exclusive,omitempty"`
+}
+
+// state is the on-disk representation persisted to StatePath.
+type state struct {
+	Allocations map[string]Allocation `json:"allocations"`
+}
+
+// Allocator tracks exclusive and shared cpuset assignments across the
+// host's CPU topology and persists them so a CRI-O restart can reconcile
+// without losing track of which CPUs are exclusively owned.
+type Allocator struct {
+	mu    sync.Mutex
+	path  string
+	topo  *Topology
+	state state
+	// exclusive maps a CPU ID to the container ID that owns it exclusively.
+	exclusive map[int]string
+}
+
+// NewAllocator creates an Allocator for topo, persisting to path. If path
+// already contains state from a previous run, it is loaded and the
+// exclusive-CPU bookkeeping is rebuilt from it.
+func NewAllocator(path string, topo *Topology) (*Allocator, error) {
+	a := &Allocator{
+		path:      path,
+		topo:      topo,
+		state:     state{Allocations: make(map[string]Allocation)},
+		exclusive: make(map[int]string),
+	}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return a, nil
+	case err != nil:
+		return nil, fmt.Errorf("read QoS CPU allocator state %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(b, &a.state); err != nil {
+		return nil, fmt.Errorf("parse QoS CPU allocator state %s: %w", path, err)
+	}
+	for id, alloc := range a.state.Allocations {
+		if !alloc.Exclusive {
+			continue
+		}
+		for _, cpu := range alloc.CPUs {
+			a.exclusive[cpu] = id
+		}
+	}
+	logrus.Infof("Reconciled %d QoS CPU allocations from %s", len(a.state.Allocations), path)
+	return a, nil
+}
+
+// freeExclusiveCPUs returns the CPUs on socket (or any socket if nil) that
+// are not currently owned exclusively by another container, grouped by
+// whole cores (all SMT siblings free together).
+func (a *Allocator) freeExclusiveCPUs(socket *int) []int {
+	seenCore := map[int]bool{}
+	var free []int
+	for _, id := range a.topo.CPUsOnSocket(socket) {
+		info := a.topo.CPUs[id]
+		coreKey := info.Socket*1000 + info.Core
+		if seenCore[coreKey] {
+			continue
+		}
+		available := true
+		for _, sib := range info.Siblings {
+			if _, used := a.exclusive[sib]; used {
+				available = false
+				break
+			}
+		}
+		if available {
+			seenCore[coreKey] = true
+			free = append(free, info.Siblings...)
+		}
+	}
+	return free
+}
+
+// sharedCPUs returns every CPU not currently exclusively owned.
+func (a *Allocator) sharedCPUs() []int {
+	var shared []int
+	for id := range a.topo.CPUs {
+		if _, used := a.exclusive[id]; !used {
+			shared = append(shared, id)
+		}
+	}
+	return shared
+}
+
+// Acquire resolves spec for containerID against the current allocation
+// state and records the result. For an exclusive class it grants one free
+// core's worth of CPUs (including SMT siblings) on the requested socket,
+// failing admission if none are available. For a shared class it returns
+// every CPU not currently held exclusively.
+func (a *Allocator) Acquire(containerID, class string, spec ClassSpec) (Allocation, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if existing, ok := a.state.Allocations[containerID]; ok {
+		return existing, nil
+	}
+
+	var cpus []int
+	if spec.Exclusive {
+		free := a.freeExclusiveCPUs(spec.Socket)
+		if len(free) == 0 {
+			return Allocation{}, fmt.Errorf("no free exclusive CPUs available for class %q", class)
+		}
+		// Grant exactly one core (its siblings), the smallest unit we hand
+		// out exclusively.
+		coreInfo := a.topo.CPUs[free[0]]
+		cpus = append([]int(nil), coreInfo.Siblings...)
+	} else {
+		cpus = a.sharedCPUs()
+		if len(cpus) == 0 {
+			return Allocation{}, fmt.Errorf("no CPUs available for shared class %q", class)
+		}
+	}
+
+	mems := memsForCPUs(a.topo, cpus)
+	alloc := Allocation{Class: class, CPUs: cpus, Mems: mems, Exclusive: spec.Exclusive}
+
+	if spec.Exclusive {
+		for _, c := range cpus {
+			a.exclusive[c] = containerID
+		}
+	}
+	a.state.Allocations[containerID] = alloc
+
+	if err := a.persistLocked(); err != nil {
+		// Roll back in-memory state so a failed persist can't leave us
+		// thinking CPUs are allocated that a restart won't know about.
+		delete(a.state.Allocations, containerID)
+		for _, c := range cpus {
+			delete(a.exclusive, c)
+		}
+		return Allocation{}, err
+	}
+
+	return alloc, nil
+}
+
+// Release frees any CPUs held exclusively by containerID.
+func (a *Allocator) Release(containerID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	alloc, ok := a.state.Allocations[containerID]
+	if !ok {
+		return
+	}
+	for _, c := range alloc.CPUs {
+		if a.exclusive[c] == containerID {
+			delete(a.exclusive, c)
+		}
+	}
+	delete(a.state.Allocations, containerID)
+
+	if err := a.persistLocked(); err != nil {
+		logrus.Errorf("Failed to persist QoS CPU allocator state after releasing %s: %v", containerID, err)
+	}
+}
+
+// persistLocked atomically writes the allocator state to a.path. Callers
+// must hold a.mu.
+func (a *Allocator) persistLocked() error {
+	b, err := json.Marshal(a.state)
+	if err != nil {
+		return fmt.Errorf("marshal QoS CPU allocator state: %w", err)
+	}
+
+	dir := filepath.Dir(a.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create QoS CPU allocator state dir %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".qos-cpu-*.tmp")
+	if err != nil {
+		return fmt.Errorf("create QoS CPU allocator temp state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write QoS CPU allocator state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close QoS CPU allocator temp state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), a.path); err != nil {
+		return fmt.Errorf("rename QoS CPU allocator state into place: %w", err)
+	}
+	return nil
+}
+
+// memsForCPUs returns the sorted, de-duplicated set of NUMA/socket IDs that
+// back cpus. We key memory nodes by socket, matching the topology CRI-O can
+// discover from sysfs without a separate NUMA node walk.
+func memsForCPUs(topo *Topology, cpus []int) []int {
+	seen := map[int]bool{}
+	var mems []int
+	for _, c := range cpus {
+		s := topo.CPUs[c].Socket
+		if !seen[s] {
+			seen[s] = true
+			mems = append(mems, s)
+		}
+	}
+	sort.Ints(mems)
+	return mems
+}
@@ -0,0 +1,63 @@
+package cpu
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cri-o/cri-o/internal/config/qos"
+)
+
+// Config is the `[crio.runtime.qos_cpu]` section of the CRI-O TOML
+// configuration file.
+type Config struct {
+	// Classes maps a class name to a cpuset expression, e.g.
+	// `latency-critical = "socket:0,exclusive"`.
+	Classes map[string]string `toml:"classes"`
+	// PodQoSFallback optionally maps a Kubernetes pod QoS class
+	// (guaranteed, burstable, besteffort) to one of the names in Classes,
+	// applied to containers that don't request a class explicitly.
+	PodQoSFallback map[string]string `toml:"pod_qos_fallback"`
+	// SysfsRoot overrides DefaultSysfsRoot; intended for testing.
+	SysfsRoot string `toml:"sysfs_root"`
+	// StatePath overrides DefaultStatePath; intended for testing.
+	StatePath string `toml:"state_path"`
+}
+
+// LoadConfig parses the TOML file at path into a Config.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		return nil, fmt.Errorf("decode QoS CPU provider config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// NewProviderFromConfig builds a Provider from cfg, applying the package
+// defaults for any unset path.
+func NewProviderFromConfig(cfg *Config) (*Provider, error) {
+	sysfsRoot := cfg.SysfsRoot
+	if sysfsRoot == "" {
+		sysfsRoot = DefaultSysfsRoot
+	}
+	statePath := cfg.StatePath
+	if statePath == "" {
+		statePath = DefaultStatePath
+	}
+	return NewProvider(cfg.Classes, sysfsRoot, statePath, cfg.PodQoSFallback)
+}
+
+// RegisterFromConfig builds a Provider from cfg via NewProviderFromConfig
+// and registers it with reg, mirroring nri.RegisterFromConfig. CRI-O's
+// startup code must call this once, after loading the `[crio.runtime.
+// qos_cpu]` config section, for the cpu provider to participate in
+// handleContainerQoSResources and getContainerQoSResourcesInfo.
+func RegisterFromConfig(reg *qos.Registry, cfg *Config) (*Provider, error) {
+	p, err := NewProviderFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := reg.RegisterProvider(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
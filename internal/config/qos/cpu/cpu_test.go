@@ -0,0 +1,247 @@
+package cpu
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cri-o/cri-o/internal/config/qos"
+)
+
+// writeFakeSysfs builds a minimal two-socket sysfs tree (two cores on
+// socket 0, one core on socket 1, two threads per core: 6 CPUs) under dir
+// and returns its path.
+func writeFakeSysfs(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	type cpu struct {
+		id, socket, core int
+		siblings         string
+	}
+	cpus := []cpu{
+		{0, 0, 0, "0,1"},
+		{1, 0, 0, "0,1"},
+		{2, 0, 1, "2,3"},
+		{3, 0, 1, "2,3"},
+		{4, 1, 0, "4,5"},
+		{5, 1, 0, "4,5"},
+	}
+	for _, c := range cpus {
+		topoDir := filepath.Join(dir, "cpu"+itoa(c.id), "topology")
+		if err := os.MkdirAll(topoDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(topoDir, "physical_package_id"), itoa(c.socket))
+		writeFile(t, filepath.Join(topoDir, "core_id"), itoa(c.core))
+		writeFile(t, filepath.Join(topoDir, "thread_siblings_list"), c.siblings)
+	}
+	return dir
+}
+
+func itoa(i int) string {
+	return string(rune('0' + i))
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscoverTopology(t *testing.T) {
+	dir := writeFakeSysfs(t)
+	topo, err := DiscoverTopology(dir)
+	if err != nil {
+		t.Fatalf("DiscoverTopology: %v", err)
+	}
+	if len(topo.CPUs) != 6 {
+		t.Fatalf("expected 6 CPUs, got %d", len(topo.CPUs))
+	}
+	if len(topo.Sockets) != 2 {
+		t.Fatalf("expected 2 sockets, got %d", len(topo.Sockets))
+	}
+	if got := topo.CPUs[2].Siblings; len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Fatalf("expected cpu2 siblings [2 3], got %v", got)
+	}
+}
+
+func TestParseClassExpr(t *testing.T) {
+	spec, err := parseClassExpr("socket:0,exclusive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Socket == nil || *spec.Socket != 0 || !spec.Exclusive {
+		t.Fatalf("unexpected spec: %+v", spec)
+	}
+
+	spec, err = parseClassExpr("shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Exclusive || spec.Socket != nil {
+		t.Fatalf("unexpected spec: %+v", spec)
+	}
+
+	if _, err := parseClassExpr("bogus"); err == nil {
+		t.Fatal("expected error for invalid expression")
+	}
+}
+
+func TestFormatCPUList(t *testing.T) {
+	if got := FormatCPUList(nil); got != "" {
+		t.Fatalf("expected empty string, got %q", got)
+	}
+	if got := FormatCPUList([]int{0}); got != "0" {
+		t.Fatalf("expected a single CPU formatted as \"0\", not a range, got %q", got)
+	}
+	if got := FormatCPUList([]int{0, 1, 2, 3, 8}); got != "0-3,8" {
+		t.Fatalf("expected 0-3,8, got %q", got)
+	}
+}
+
+func TestAllocatorExclusiveExhaustion(t *testing.T) {
+	dir := writeFakeSysfs(t)
+	topo, err := DiscoverTopology(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statePath := filepath.Join(t.TempDir(), "qos-cpu.json")
+	a, err := NewAllocator(statePath, topo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socket0 := 0
+	spec := ClassSpec{Socket: &socket0, Exclusive: true}
+
+	alloc1, err := a.Acquire("ctr-1", "latency-critical", spec)
+	if err != nil {
+		t.Fatalf("Acquire ctr-1: %v", err)
+	}
+	if len(alloc1.CPUs) != 2 {
+		t.Fatalf("expected 2 CPUs (one core's siblings), got %v", alloc1.CPUs)
+	}
+
+	alloc2, err := a.Acquire("ctr-2", "latency-critical", spec)
+	if err != nil {
+		t.Fatalf("Acquire ctr-2: %v", err)
+	}
+	for _, c := range alloc2.CPUs {
+		for _, c1 := range alloc1.CPUs {
+			if c == c1 {
+				t.Fatalf("ctr-2 was given CPU %d already exclusively owned by ctr-1", c)
+			}
+		}
+	}
+
+	// Socket 0 only has 2 cores; a third exclusive request must fail
+	// admission instead of silently sharing a core.
+	if _, err := a.Acquire("ctr-3", "latency-critical", spec); err == nil {
+		t.Fatal("expected admission failure when exclusive CPUs are exhausted")
+	}
+
+	a.Release("ctr-1")
+	if _, err := a.Acquire("ctr-3", "latency-critical", spec); err != nil {
+		t.Fatalf("Acquire ctr-3 after release: %v", err)
+	}
+
+	// A fresh allocator loading the same state file must reconcile the
+	// exclusive ownership so it survives a restart.
+	a2, err := NewAllocator(statePath, topo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a2.Acquire("ctr-4", "latency-critical", spec); err == nil {
+		t.Fatal("expected reconciled allocator to still consider socket 0 exhausted")
+	}
+}
+
+func TestNewProviderFromConfig(t *testing.T) {
+	dir := writeFakeSysfs(t)
+
+	cfg := &Config{
+		Classes: map[string]string{
+			"latency-critical": "socket:0,exclusive",
+			"best-effort":      "shared",
+		},
+		PodQoSFallback: map[string]string{
+			"guaranteed": "latency-critical",
+			"besteffort": "best-effort",
+		},
+		SysfsRoot: dir,
+		StatePath: filepath.Join(t.TempDir(), "qos-cpu.json"),
+	}
+
+	p, err := NewProviderFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewProviderFromConfig: %v", err)
+	}
+	if p.Name() != ResourceName {
+		t.Fatalf("expected name %q, got %q", ResourceName, p.Name())
+	}
+	if class, ok := p.DefaultClass("guaranteed"); !ok || class != "latency-critical" {
+		t.Fatalf("expected guaranteed fallback to latency-critical, got %q (%v)", class, ok)
+	}
+	if _, ok := p.DefaultClass("burstable"); ok {
+		t.Fatal("expected no fallback configured for burstable")
+	}
+}
+
+func TestRegisterFromConfig(t *testing.T) {
+	dir := writeFakeSysfs(t)
+
+	cfg := &Config{
+		Classes:   map[string]string{"shared-only": "shared"},
+		SysfsRoot: dir,
+		StatePath: filepath.Join(t.TempDir(), "qos-cpu.json"),
+	}
+
+	reg := qos.New()
+	p, err := RegisterFromConfig(reg, cfg)
+	if err != nil {
+		t.Fatalf("RegisterFromConfig: %v", err)
+	}
+
+	registered, ok := reg.Provider(ResourceName)
+	if !ok {
+		t.Fatal("expected cpu provider to be registered")
+	}
+	if registered != p {
+		t.Fatal("expected the registered provider to be the one RegisterFromConfig returned")
+	}
+}
+
+func TestNewProviderRejectsUnknownFallbackClass(t *testing.T) {
+	dir := writeFakeSysfs(t)
+	_, err := NewProvider(
+		map[string]string{"shared-only": "shared"},
+		dir,
+		filepath.Join(t.TempDir(), "qos-cpu.json"),
+		map[string]string{"guaranteed": "does-not-exist"},
+	)
+	if err == nil {
+		t.Fatal("expected error for fallback referring to an undefined class")
+	}
+}
+
+func TestAllocatorShared(t *testing.T) {
+	dir := writeFakeSysfs(t)
+	topo, err := DiscoverTopology(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAllocator(filepath.Join(t.TempDir(), "qos-cpu.json"), topo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alloc, err := a.Acquire("ctr-shared", "best-effort", ClassSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alloc.CPUs) != 6 {
+		t.Fatalf("expected all 6 CPUs in the shared pool, got %v", alloc.CPUs)
+	}
+}
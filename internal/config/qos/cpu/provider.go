@@ -0,0 +1,163 @@
+package cpu
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cri-o/cri-o/internal/config/qos"
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// ResourceName is the CRI QoS resource name handled by Provider.
+const ResourceName = "cpu"
+
+// ClassSpec is a parsed class expression, e.g. "socket:0,exclusive" or
+// "shared".
+type ClassSpec struct {
+	Socket    *int
+	Exclusive bool
+}
+
+// parseClassExpr parses a class expression of the form
+// "[socket:N,]exclusive" or "shared".
+func parseClassExpr(expr string) (ClassSpec, error) {
+	var spec ClassSpec
+	for _, field := range strings.Split(expr, ",") {
+		field = strings.TrimSpace(field)
+		switch {
+		case field == "shared":
+			spec.Exclusive = false
+		case field == "exclusive":
+			spec.Exclusive = true
+		case strings.HasPrefix(field, "socket:"):
+			n, err := strconv.Atoi(strings.TrimPrefix(field, "socket:"))
+			if err != nil {
+				return ClassSpec{}, fmt.Errorf("invalid socket in class expression %q: %w", expr, err)
+			}
+			spec.Socket = &n
+		case field == "":
+			continue
+		default:
+			return ClassSpec{}, fmt.Errorf("invalid class expression %q", expr)
+		}
+	}
+	return spec, nil
+}
+
+// Provider is a qos.Provider that resolves cpuset QoS classes against the
+// host's CPU topology.
+type Provider struct {
+	classDefs      map[string]ClassSpec
+	order          []string
+	allocator      *Allocator
+	podQoSFallback map[string]string
+}
+
+// PodQoSClassFromCgroupParent infers the pod's Kubernetes QoS class
+// (guaranteed, burstable or besteffort) from its cgroup parent path,
+// mirroring the convention the kubelet uses when constructing the cgroup
+// hierarchy (".../kubepods/besteffort/<podUID>/...", etc).
+func PodQoSClassFromCgroupParent(cgroupParent string) string {
+	switch {
+	case strings.Contains(cgroupParent, "besteffort"):
+		return "besteffort"
+	case strings.Contains(cgroupParent, "burstable"):
+		return "burstable"
+	default:
+		return "guaranteed"
+	}
+}
+
+// DefaultClass returns the class this provider falls back to for a
+// container whose pod has podQoSClass and that did not request a CPU QoS
+// class explicitly, if a fallback has been configured for it.
+func (p *Provider) DefaultClass(podQoSClass string) (string, bool) {
+	class, ok := p.podQoSFallback[podQoSClass]
+	return class, ok
+}
+
+// NewProvider builds a Provider from classExprs (class name -> expression,
+// e.g. "latency-critical" -> "socket:0,exclusive"), discovering topology
+// under sysfsRoot and persisting allocations to statePath. podQoSFallback
+// optionally maps a Kubernetes pod QoS class (guaranteed/burstable/
+// besteffort) to one of the class names in classExprs, used when a
+// container doesn't request a CPU QoS class explicitly.
+func NewProvider(classExprs map[string]string, sysfsRoot, statePath string, podQoSFallback map[string]string) (*Provider, error) {
+	topo, err := DiscoverTopology(sysfsRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	classDefs := make(map[string]ClassSpec, len(classExprs))
+	order := make([]string, 0, len(classExprs))
+	for name, expr := range classExprs {
+		spec, err := parseClassExpr(expr)
+		if err != nil {
+			return nil, fmt.Errorf("class %q: %w", name, err)
+		}
+		classDefs[name] = spec
+		order = append(order, name)
+	}
+	sort.Strings(order)
+
+	allocator, err := NewAllocator(statePath, topo)
+	if err != nil {
+		return nil, err
+	}
+
+	for podQoSClass, class := range podQoSFallback {
+		if _, ok := classDefs[class]; !ok {
+			return nil, fmt.Errorf("pod QoS fallback for %q refers to undefined class %q", podQoSClass, class)
+		}
+	}
+
+	return &Provider{classDefs: classDefs, order: order, allocator: allocator, podQoSFallback: podQoSFallback}, nil
+}
+
+// Name implements qos.Provider.
+func (p *Provider) Name() string { return ResourceName }
+
+// Scope implements qos.Provider. A cpuset is requested and applied per
+// container, never for a whole pod sandbox.
+func (p *Provider) Scope() qos.Scope { return qos.ScopeContainer }
+
+// Classes implements qos.Provider.
+func (p *Provider) Classes() []string { return p.order }
+
+// Mutable implements qos.Provider. CPU placement is resolved once at
+// create time and is not currently reassignable without a container
+// restart.
+func (p *Provider) Mutable() bool { return false }
+
+// Apply implements qos.Provider. It resolves class to a concrete cpuset,
+// admitting the request only if enough CPUs are available, and writes the
+// result into spec.Linux.Resources.CPU.
+func (p *Provider) Apply(spec *rspec.Spec, containerID, class string) error {
+	classSpec, ok := p.classDefs[class]
+	if !ok {
+		return fmt.Errorf("unknown %s class %q", ResourceName, class)
+	}
+
+	alloc, err := p.allocator.Acquire(containerID, class, classSpec)
+	if err != nil {
+		return fmt.Errorf("admission failed for %s class %q: %w", ResourceName, class, err)
+	}
+
+	if spec.Linux.Resources == nil {
+		spec.Linux.Resources = &rspec.LinuxResources{}
+	}
+	if spec.Linux.Resources.CPU == nil {
+		spec.Linux.Resources.CPU = &rspec.LinuxCPU{}
+	}
+	spec.Linux.Resources.CPU.Cpus = FormatCPUList(alloc.CPUs)
+	spec.Linux.Resources.CPU.Mems = FormatCPUList(alloc.Mems)
+
+	return nil
+}
+
+// Release implements qos.Releaser.
+func (p *Provider) Release(containerID string) {
+	p.allocator.Release(containerID)
+}
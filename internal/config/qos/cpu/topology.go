@@ -0,0 +1,185 @@
+// Package cpu implements a topology-aware cpuset QoS resource provider.
+//
+// Classes are defined in the CRI-O config file as cpuset expressions (e.g.
+// `latency-critical = "socket:0,exclusive"`, `best-effort = "shared"`) and
+// resolved against the host's CPU topology at allocation time.
+package cpu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultSysfsRoot is the default location used to discover CPU topology.
+const DefaultSysfsRoot = "/sys/devices/system/cpu"
+
+// CPUInfo describes the placement of a single logical CPU.
+type CPUInfo struct {
+	ID       int
+	Socket   int
+	Core     int
+	Siblings []int // SMT thread siblings sharing Core, including ID itself
+}
+
+// Topology is the host's CPU topology, as discovered from sysfs.
+type Topology struct {
+	CPUs    map[int]*CPUInfo
+	Sockets []int
+}
+
+var cpuDirRegexp = regexp.MustCompile(`^cpu(\d+)$`)
+
+// DiscoverTopology walks sysfsRoot (normally DefaultSysfsRoot) and builds a
+// Topology from the physical_package_id/core_id/thread_siblings_list files
+// exposed for every online CPU.
+func DiscoverTopology(sysfsRoot string) (*Topology, error) {
+	entries, err := os.ReadDir(sysfsRoot)
+	if err != nil {
+		return nil, fmt.Errorf("read sysfs cpu directory %s: %w", sysfsRoot, err)
+	}
+
+	topo := &Topology{CPUs: make(map[int]*CPUInfo)}
+	sockets := map[int]struct{}{}
+
+	for _, e := range entries {
+		m := cpuDirRegexp.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		id, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+
+		topologyDir := filepath.Join(sysfsRoot, e.Name(), "topology")
+		socket, err := readIntFile(filepath.Join(topologyDir, "physical_package_id"))
+		if err != nil {
+			// CPU may be offline and lack a topology directory; skip it.
+			continue
+		}
+		core, err := readIntFile(filepath.Join(topologyDir, "core_id"))
+		if err != nil {
+			return nil, err
+		}
+		siblings, err := readListFile(filepath.Join(topologyDir, "thread_siblings_list"))
+		if err != nil {
+			return nil, err
+		}
+
+		topo.CPUs[id] = &CPUInfo{ID: id, Socket: socket, Core: core, Siblings: siblings}
+		sockets[socket] = struct{}{}
+	}
+
+	for s := range sockets {
+		topo.Sockets = append(topo.Sockets, s)
+	}
+	sort.Ints(topo.Sockets)
+
+	if len(topo.CPUs) == 0 {
+		return nil, fmt.Errorf("no CPUs discovered under %s", sysfsRoot)
+	}
+
+	return topo, nil
+}
+
+// CPUsOnSocket returns the sorted IDs of every CPU on socket, or every CPU
+// on the host if socket is nil.
+func (t *Topology) CPUsOnSocket(socket *int) []int {
+	out := []int{}
+	for id, info := range t.CPUs {
+		if socket == nil || info.Socket == *socket {
+			out = append(out, id)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+func readIntFile(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+// readListFile parses a Linux list-format file, e.g. "0-3,8" or "2,3".
+func readListFile(path string) ([]int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseCPUList(strings.TrimSpace(string(b)))
+}
+
+func parseCPUList(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		if part == "" {
+			continue
+		}
+		if dash := strings.IndexByte(part, '-'); dash >= 0 {
+			lo, err := strconv.Atoi(part[:dash])
+			if err != nil {
+				return nil, fmt.Errorf("parse cpu list %q: %w", s, err)
+			}
+			hi, err := strconv.Atoi(part[dash+1:])
+			if err != nil {
+				return nil, fmt.Errorf("parse cpu list %q: %w", s, err)
+			}
+			for i := lo; i <= hi; i++ {
+				out = append(out, i)
+			}
+			continue
+		}
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("parse cpu list %q: %w", s, err)
+		}
+		out = append(out, v)
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+// FormatCPUList renders cpus (e.g. [0,1,2,3]) as a cpuset-style string, e.g.
+// "0-3".
+func FormatCPUList(cpus []int) string {
+	if len(cpus) == 0 {
+		return ""
+	}
+	sorted := append([]int(nil), cpus...)
+	sort.Ints(sorted)
+
+	var b strings.Builder
+	start := sorted[0]
+	prev := sorted[0]
+	flush := func(end int) {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		if start == end {
+			fmt.Fprintf(&b, "%d", start)
+		} else {
+			fmt.Fprintf(&b, "%d-%d", start, end)
+		}
+	}
+	for _, c := range sorted[1:] {
+		if c == prev+1 {
+			prev = c
+			continue
+		}
+		flush(prev)
+		start, prev = c, c
+	}
+	flush(prev)
+	return b.String()
+}
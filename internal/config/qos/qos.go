@@ -0,0 +1,252 @@
+// Package qos implements a pluggable subsystem for CRI QoS resources.
+//
+// Unlike RDT and BlockIO, which are first-class CRI-O subsystems with their
+// own cgroup/resctrl plumbing, the providers managed here are registered at
+// runtime (either by Go code via RegisterProvider, or declared in the CRI-O config
+// file) and are dispatched to generically from the server package.
+package qos
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
+	types "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// Scope identifies whether a Provider's resource is reported to, and
+// requestable from, the kubelet at the pod level or the container level.
+type Scope string
+
+const (
+	// ScopePod is for providers whose resource applies to an entire pod
+	// sandbox.
+	ScopePod Scope = "pod"
+	// ScopeContainer is for providers whose resource is requested and
+	// applied per container (e.g. a cpuset or RDT class).
+	ScopeContainer Scope = "container"
+)
+
+// Provider is implemented by a pluggable QoS resource provider. A Provider
+// owns a single CRI QoS resource name (e.g. "vendor.com/numa") and the set
+// of classes valid for it.
+type Provider interface {
+	// Name returns the CRI QoS resource name this provider handles.
+	Name() string
+	// Scope reports whether this resource is a pod-level or
+	// container-level QoS resource.
+	Scope() Scope
+	// Classes returns the names of the classes this provider supports, in
+	// the order they should be reported to the kubelet.
+	Classes() []string
+	// Mutable reports whether a container's class may be changed after the
+	// container has been created via UpdateContainerResources.
+	Mutable() bool
+	// Apply mutates spec so containerID is placed into class.
+	Apply(spec *rspec.Spec, containerID, class string) error
+}
+
+// Releaser is an optional interface a Provider may implement when it keeps
+// per-container state that must be cleaned up when the container goes
+// away (e.g. an allocator tracking exclusive resource ownership).
+type Releaser interface {
+	// Release frees any state held for containerID.
+	Release(containerID string)
+}
+
+// Reassigner is an optional interface a mutable Provider implements to
+// support UpdateContainerResources reassigning an already-created
+// container to a different class without restarting it.
+type Reassigner interface {
+	// Reassign moves containerID from its current class to class.
+	Reassign(containerID, class string) error
+}
+
+// PodQoSFallback is an optional interface a Provider may implement to pick
+// a class automatically for a container that didn't request one
+// explicitly, based on the Kubernetes QoS class of its pod (guaranteed,
+// burstable or besteffort).
+type PodQoSFallback interface {
+	// DefaultClass returns the class to apply for podQoSClass, if any.
+	DefaultClass(podQoSClass string) (class string, ok bool)
+}
+
+// Registry tracks the set of registered QoS resource providers and
+// dispatches requests to them.
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+}
+
+// New creates an empty provider Registry.
+func New() *Registry {
+	return &Registry{
+		providers: make(map[string]Provider),
+	}
+}
+
+var (
+	defaultRegistryMu sync.Mutex
+	defaultRegistry   *Registry
+)
+
+// DefaultRegistry returns the process-wide Registry, creating it on first
+// use. CRI-O's server package dispatches every QoS resource request
+// against this Registry; CRI-O's startup code populates it by calling
+// SetupFromConfig, mirroring rdt.DefaultMonitor and nri.DefaultRegistry.
+func DefaultRegistry() *Registry {
+	defaultRegistryMu.Lock()
+	defer defaultRegistryMu.Unlock()
+	if defaultRegistry == nil {
+		defaultRegistry = New()
+	}
+	return defaultRegistry
+}
+
+// RegisterProvider adds p to the registry. It returns an error if a provider
+// is already registered for p.Name().
+func (r *Registry) RegisterProvider(p Provider) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.providers[p.Name()]; ok {
+		return fmt.Errorf("QoS resource provider %q already registered", p.Name())
+	}
+	r.providers[p.Name()] = p
+	logrus.Infof("Registered QoS resource provider %q with classes %v", p.Name(), p.Classes())
+	return nil
+}
+
+// UnregisterProvider removes the provider registered for name, if any.
+func (r *Registry) UnregisterProvider(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.providers, name)
+}
+
+// Provider returns the provider registered for name, if any.
+func (r *Registry) Provider(name string) (Provider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// Providers returns all registered providers, sorted by name for stable
+// output.
+func (r *Registry) Providers() []Provider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]Provider, 0, len(r.providers))
+	for _, p := range r.providers {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out
+}
+
+// ResourceInfos converts the registered providers whose Scope matches
+// scope into the CRI QoSResourceInfo representation expected by
+// Server.getPodQoSResourcesInfo / getContainerQoSResourcesInfo. A
+// container-scoped provider (e.g. the "cpu" provider) is never reported
+// as a pod-level resource, and vice versa.
+func (r *Registry) ResourceInfos(scope Scope) []*types.QoSResourceInfo {
+	providers := r.Providers()
+	out := make([]*types.QoSResourceInfo, 0, len(providers))
+	for _, p := range providers {
+		if p.Scope() != scope {
+			continue
+		}
+		classes := p.Classes()
+		classInfos := make([]*types.QoSResourceClassInfo, len(classes))
+		for i, c := range classes {
+			classInfos[i] = &types.QoSResourceClassInfo{Name: c, Capacity: uint64(i)}
+		}
+		out = append(out, &types.QoSResourceInfo{
+			Name:    p.Name(),
+			Mutable: p.Mutable(),
+			Classes: classInfos,
+		})
+	}
+	return out
+}
+
+// Validate checks that class is a class known to the provider registered
+// for resource. It returns an error if the resource or the class is
+// unknown.
+func (r *Registry) Validate(resource, class string) error {
+	p, ok := r.Provider(resource)
+	if !ok {
+		return fmt.Errorf("unknown QoS resource type %q", resource)
+	}
+	for _, c := range p.Classes() {
+		if c == class {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s class %q", resource, class)
+}
+
+// Apply validates resource/class and, if valid, applies it to spec via the
+// registered provider.
+func (r *Registry) Apply(spec *rspec.Spec, containerID, resource, class string) error {
+	if err := r.Validate(resource, class); err != nil {
+		return err
+	}
+	p, _ := r.Provider(resource)
+	return p.Apply(spec, containerID, class)
+}
+
+// ApplyPodQoSFallbacks applies, for every registered provider that
+// implements PodQoSFallback and whose resource was not already requested
+// explicitly, the class that provider maps podQoSClass to, if any.
+func (r *Registry) ApplyPodQoSFallbacks(spec *rspec.Spec, containerID, podQoSClass string, requested map[string]string) error {
+	for _, p := range r.Providers() {
+		if _, ok := requested[p.Name()]; ok {
+			continue
+		}
+		fb, ok := p.(PodQoSFallback)
+		if !ok {
+			continue
+		}
+		class, ok := fb.DefaultClass(podQoSClass)
+		if !ok {
+			continue
+		}
+		if err := p.Apply(spec, containerID, class); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reassign moves containerID to class for resource on a provider that
+// supports live reassignment. It returns an error if the resource/class is
+// unknown, the provider isn't mutable, or it doesn't implement Reassigner.
+func (r *Registry) Reassign(containerID, resource, class string) error {
+	if err := r.Validate(resource, class); err != nil {
+		return err
+	}
+	p, _ := r.Provider(resource)
+	if !p.Mutable() {
+		return fmt.Errorf("QoS resource %q is not mutable", resource)
+	}
+	reassigner, ok := p.(Reassigner)
+	if !ok {
+		return fmt.Errorf("QoS resource %q does not support reassignment", resource)
+	}
+	return reassigner.Reassign(containerID, class)
+}
+
+// Release notifies every registered provider that implements Releaser that
+// containerID is gone, so any resources it held exclusively can be freed.
+func (r *Registry) Release(containerID string) {
+	for _, p := range r.Providers() {
+		if releaser, ok := p.(Releaser); ok {
+			releaser.Release(containerID)
+		}
+	}
+}
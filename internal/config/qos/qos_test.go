@@ -0,0 +1,218 @@
+package qos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+type fakeProvider struct {
+	name    string
+	classes []string
+	mutable bool
+	scope   Scope
+	applied string
+}
+
+func (f *fakeProvider) Name() string { return f.name }
+func (f *fakeProvider) Scope() Scope {
+	if f.scope == "" {
+		return ScopeContainer
+	}
+	return f.scope
+}
+func (f *fakeProvider) Classes() []string { return f.classes }
+func (f *fakeProvider) Mutable() bool     { return f.mutable }
+func (f *fakeProvider) Apply(spec *rspec.Spec, containerID, class string) error {
+	f.applied = class
+	return nil
+}
+
+func TestRegisterAndDispatch(t *testing.T) {
+	r := New()
+	p := &fakeProvider{name: "vendor.com/numa", classes: []string{"near", "far"}}
+	if err := r.RegisterProvider(p); err != nil {
+		t.Fatalf("RegisterProvider: %v", err)
+	}
+
+	if err := r.RegisterProvider(p); err == nil {
+		t.Fatal("expected error registering duplicate provider")
+	}
+
+	if err := r.Validate("vendor.com/numa", "near"); err != nil {
+		t.Fatalf("Validate valid class: %v", err)
+	}
+	if err := r.Validate("vendor.com/numa", "nope"); err == nil {
+		t.Fatal("expected error for unknown class")
+	}
+	if err := r.Validate("nope", "near"); err == nil {
+		t.Fatal("expected error for unknown resource")
+	}
+
+	spec := &rspec.Spec{}
+	if err := r.Apply(spec, "ctr-1", "vendor.com/numa", "far"); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if p.applied != "far" {
+		t.Fatalf("expected provider to be applied with class %q, got %q", "far", p.applied)
+	}
+
+	r.UnregisterProvider("vendor.com/numa")
+	if _, ok := r.Provider("vendor.com/numa"); ok {
+		t.Fatal("expected provider to be unregistered")
+	}
+}
+
+func TestResourceInfos(t *testing.T) {
+	r := New()
+	if err := r.RegisterProvider(&fakeProvider{name: "b", classes: []string{"x"}, mutable: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RegisterProvider(&fakeProvider{name: "a", classes: []string{"y", "z"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	infos := r.ResourceInfos(ScopeContainer)
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 resource infos, got %d", len(infos))
+	}
+	if infos[0].Name != "a" || infos[1].Name != "b" {
+		t.Fatalf("expected infos sorted by name, got %q then %q", infos[0].Name, infos[1].Name)
+	}
+	if !infos[1].Mutable {
+		t.Fatal("expected provider b to be reported mutable")
+	}
+}
+
+func TestResourceInfosScopesPodAndContainerSeparately(t *testing.T) {
+	r := New()
+	if err := r.RegisterProvider(&fakeProvider{name: "cpu", classes: []string{"shared"}, scope: ScopeContainer}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RegisterProvider(&fakeProvider{name: "vendor.com/bandwidth", classes: []string{"gold"}, scope: ScopePod}); err != nil {
+		t.Fatal(err)
+	}
+
+	containerInfos := r.ResourceInfos(ScopeContainer)
+	if len(containerInfos) != 1 || containerInfos[0].Name != "cpu" {
+		t.Fatalf("expected only the container-scoped provider, got %+v", containerInfos)
+	}
+
+	podInfos := r.ResourceInfos(ScopePod)
+	if len(podInfos) != 1 || podInfos[0].Name != "vendor.com/bandwidth" {
+		t.Fatalf("expected only the pod-scoped provider, got %+v", podInfos)
+	}
+}
+
+func TestLoadConfigAndReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "qos.toml")
+	const contents = `
+[provider.custom-1]
+classes = ["gold", "silver"]
+mutable = true
+`
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New()
+	if err := r.Reload(path); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	p, ok := r.Provider("custom-1")
+	if !ok {
+		t.Fatal("expected custom-1 provider to be registered")
+	}
+	if !p.Mutable() {
+		t.Fatal("expected custom-1 to be mutable")
+	}
+
+	if err := r.Validate("custom-1", "gold"); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	// Reloading again must not error out on "already registered".
+	if err := r.Reload(path); err != nil {
+		t.Fatalf("second Reload: %v", err)
+	}
+}
+
+// TestReassignMutableConfigProvider mirrors the Kubelet-facing scenario
+// the request asked for: a container is flipped between two classes of a
+// config-declared provider via UpdateContainerResources (modeled here as
+// Registry.Reassign, what server.reassignContainerQoSClass dispatches
+// to), without anything resembling a container restart happening.
+func TestReassignMutableConfigProvider(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "qos.toml")
+	const contents = `
+[provider.custom-1]
+classes = ["latency-critical", "best-effort"]
+mutable = true
+`
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New()
+	if err := r.Reload(path); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	spec := &rspec.Spec{}
+	if err := r.Apply(spec, "ctr-1", "custom-1", "latency-critical"); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if err := r.Reassign("ctr-1", "custom-1", "best-effort"); err != nil {
+		t.Fatalf("Reassign to best-effort: %v", err)
+	}
+	if err := r.Reassign("ctr-1", "custom-1", "latency-critical"); err != nil {
+		t.Fatalf("Reassign back to latency-critical: %v", err)
+	}
+}
+
+// TestReassignRejectsImmutableProvider makes sure a provider that wasn't
+// declared mutable still refuses reassignment, so Reassign's mutability
+// check isn't bypassed by staticProvider always implementing Reassigner.
+func TestReassignRejectsImmutableProvider(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "qos.toml")
+	const contents = `
+[provider.custom-1]
+classes = ["gold", "silver"]
+`
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New()
+	if err := r.Reload(path); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if err := r.Reassign("ctr-1", "custom-1", "silver"); err == nil {
+		t.Fatal("expected error reassigning an immutable provider")
+	}
+}
+
+func TestLoadConfigRejectsEmptyClasses(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "qos.toml")
+	const contents = `
+[provider.empty]
+classes = []
+`
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New()
+	if err := r.Reload(path); err == nil {
+		t.Fatal("expected error for provider with no classes")
+	}
+}
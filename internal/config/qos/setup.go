@@ -0,0 +1,18 @@
+package qos
+
+import "fmt"
+
+// SetupFromConfig loads path (the `[qos]` section of the CRI-O config
+// file) into DefaultRegistry, starts watching it for SIGHUP reloads, and
+// returns a stop function that ends the watch.
+//
+// CRI-O's startup code (internal/lib/config.go / server/server.go) must
+// call this once, after the main config file has been loaded, before the
+// server starts accepting CRI requests; the returned stop func should be
+// called on shutdown, alongside the server's other teardown steps.
+func SetupFromConfig(path string) (stop func(), err error) {
+	if err := DefaultRegistry().Reload(path); err != nil {
+		return nil, fmt.Errorf("load QoS provider config: %w", err)
+	}
+	return DefaultRegistry().WatchSIGHUP(path), nil
+}
@@ -0,0 +1,33 @@
+package qos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetupFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "qos.toml")
+	const contents = `
+[provider.custom-1]
+classes = ["gold", "silver"]
+`
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	defaultRegistryMu.Lock()
+	defaultRegistry = nil
+	defaultRegistryMu.Unlock()
+
+	stop, err := SetupFromConfig(path)
+	if err != nil {
+		t.Fatalf("SetupFromConfig: %v", err)
+	}
+	defer stop()
+
+	if _, ok := DefaultRegistry().Provider("custom-1"); !ok {
+		t.Fatal("expected custom-1 provider to be registered on DefaultRegistry")
+	}
+}
@@ -0,0 +1,131 @@
+package qos
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultClassStorePath is where a container's current QoS classes are
+// persisted so a CRI-O restart can reconcile them without having to ask
+// every container anew.
+const DefaultClassStorePath = "/var/lib/crio/qos-classes.json"
+
+// ClassStore persists, per container, the QoS class currently applied for
+// each resource. It backs UpdateContainerResources so a reassignment
+// survives a CRI-O restart.
+type ClassStore struct {
+	mu sync.Mutex
+
+	path string
+	// containerID -> resource -> class
+	classes map[string]map[string]string
+}
+
+var (
+	defaultClassStoreMu sync.Mutex
+	defaultClassStore   *ClassStore
+)
+
+// DefaultClassStore returns the process-wide ClassStore, backed by
+// DefaultClassStorePath, creating (and loading) it on first use. A failed
+// attempt (e.g. an existing DefaultClassStorePath that cannot be read or
+// parsed) is not cached, so a later call can succeed once the problem
+// clears.
+func DefaultClassStore() (*ClassStore, error) {
+	defaultClassStoreMu.Lock()
+	defer defaultClassStoreMu.Unlock()
+
+	if defaultClassStore != nil {
+		return defaultClassStore, nil
+	}
+
+	s, err := NewClassStore(DefaultClassStorePath)
+	if err != nil {
+		return nil, err
+	}
+	defaultClassStore = s
+	return defaultClassStore, nil
+}
+
+// NewClassStore creates a ClassStore persisting to path, loading any state
+// already there.
+func NewClassStore(path string) (*ClassStore, error) {
+	s := &ClassStore{path: path, classes: make(map[string]map[string]string)}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return s, nil
+	case err != nil:
+		return nil, fmt.Errorf("read QoS class store %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(b, &s.classes); err != nil {
+		return nil, fmt.Errorf("parse QoS class store %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Get returns the class currently recorded for containerID/resource.
+func (s *ClassStore) Get(containerID, resource string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	class, ok := s.classes[containerID][resource]
+	return class, ok
+}
+
+// Set records that containerID is in class for resource, persisting the
+// change.
+func (s *ClassStore) Set(containerID, resource, class string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.classes[containerID] == nil {
+		s.classes[containerID] = make(map[string]string)
+	}
+	s.classes[containerID][resource] = class
+	return s.persistLocked()
+}
+
+// Remove forgets every class recorded for containerID, e.g. once it has
+// been removed.
+func (s *ClassStore) Remove(containerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.classes[containerID]; !ok {
+		return nil
+	}
+	delete(s.classes, containerID)
+	return s.persistLocked()
+}
+
+func (s *ClassStore) persistLocked() error {
+	b, err := json.Marshal(s.classes)
+	if err != nil {
+		return fmt.Errorf("marshal QoS class store: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create QoS class store dir %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".qos-classes-*.tmp")
+	if err != nil {
+		return fmt.Errorf("create QoS class store temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write QoS class store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close QoS class store temp file: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
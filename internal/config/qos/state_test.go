@@ -0,0 +1,67 @@
+package qos
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestClassStorePersistsAcrossReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "qos-classes.json")
+
+	s, err := NewClassStore(path)
+	if err != nil {
+		t.Fatalf("NewClassStore: %v", err)
+	}
+	if err := s.Set("ctr-1", "rdt", "latency-critical"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	reloaded, err := NewClassStore(path)
+	if err != nil {
+		t.Fatalf("NewClassStore (reload): %v", err)
+	}
+	class, ok := reloaded.Get("ctr-1", "rdt")
+	if !ok || class != "latency-critical" {
+		t.Fatalf("expected reloaded store to recall ctr-1/rdt=latency-critical, got %q (%v)", class, ok)
+	}
+
+	if err := reloaded.Remove("ctr-1"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, ok := reloaded.Get("ctr-1", "rdt"); ok {
+		t.Fatal("expected class to be forgotten after Remove")
+	}
+}
+
+type reassignableProvider struct {
+	fakeProvider
+	last string
+}
+
+func (r *reassignableProvider) Reassign(containerID, class string) error {
+	r.last = class
+	return nil
+}
+
+func TestRegistryReassign(t *testing.T) {
+	r := New()
+	p := &reassignableProvider{fakeProvider: fakeProvider{name: "cpu", classes: []string{"a", "b"}, mutable: true}}
+	if err := r.RegisterProvider(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Reassign("ctr-1", "cpu", "b"); err != nil {
+		t.Fatalf("Reassign: %v", err)
+	}
+	if p.last != "b" {
+		t.Fatalf("expected provider to be reassigned to class b, got %q", p.last)
+	}
+
+	immutable := &fakeProvider{name: "immutable-thing", classes: []string{"x"}}
+	if err := r.RegisterProvider(immutable); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reassign("ctr-1", "immutable-thing", "x"); err == nil {
+		t.Fatal("expected error reassigning an immutable provider")
+	}
+}
@@ -0,0 +1,288 @@
+package rdt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultResctrlRoot is the standard mount point of the resctrl
+// filesystem.
+const DefaultResctrlRoot = "/sys/fs/resctrl"
+
+// ResctrlPrefix is prepended to an RDT class name to get the name of its
+// top-level resctrl CLOS directory (e.g. class "latency-critical" lives
+// at "<resctrlRoot>/crio-latency-critical"), so CRI-O's classes never
+// collide with a user-created resctrl group.
+const ResctrlPrefix = "crio-"
+
+// monGroupPrefix is prepended to a container ID to name its monitoring
+// group directory, so it never collides with a user-created group.
+const monGroupPrefix = "crio-"
+
+// Sample is a single CMT/MBM reading for one container.
+type Sample struct {
+	// LLCOccupancyBytes is the CMT cache occupancy, in bytes.
+	LLCOccupancyBytes uint64
+	// MBMLocalBytes is the cumulative local memory bandwidth, in bytes.
+	MBMLocalBytes uint64
+	// MBMTotalBytes is the cumulative total memory bandwidth, in bytes.
+	MBMTotalBytes uint64
+}
+
+type monGroup struct {
+	class string
+	path  string
+}
+
+// Monitor polls resctrl monitoring groups for CMT/MBM counters on behalf
+// of containers that have an RDT class assigned.
+type Monitor struct {
+	mu          sync.RWMutex
+	resctrlRoot string
+	interval    time.Duration
+	groups      map[string]*monGroup // containerID -> group
+	samples     map[string]Sample    // containerID -> last sample
+	pids        map[string][]int     // containerID -> attached PIDs
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// NewMonitor creates a Monitor that polls resctrlRoot every interval once
+// Start is called.
+func NewMonitor(resctrlRoot string, interval time.Duration) *Monitor {
+	return &Monitor{
+		resctrlRoot: resctrlRoot,
+		interval:    interval,
+		groups:      make(map[string]*monGroup),
+		samples:     make(map[string]Sample),
+		pids:        make(map[string][]int),
+		stopCh:      make(chan struct{}),
+	}
+}
+
+var (
+	defaultMonitor     *Monitor
+	defaultMonitorOnce sync.Once
+)
+
+// DefaultMonitor returns the process-wide Monitor, creating it and
+// starting its polling loop on first use.
+func DefaultMonitor() *Monitor {
+	defaultMonitorOnce.Do(func() {
+		defaultMonitor = NewMonitor(DefaultResctrlRoot, 5*time.Second)
+		defaultMonitor.Start()
+	})
+	return defaultMonitor
+}
+
+func (m *Monitor) monGroupPath(class, containerID string) string {
+	return filepath.Join(m.resctrlRoot, ResctrlPrefix+class, "mon_groups", monGroupPrefix+containerID)
+}
+
+// EnsureMonGroup creates the resctrl monitoring group for containerID
+// under class if it doesn't already exist. It is idempotent and safe to
+// call before the container's PID is known; call AttachPID once it is.
+func (m *Monitor) EnsureMonGroup(containerID, class string) error {
+	path := m.monGroupPath(class, containerID)
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return fmt.Errorf("create RDT monitoring group %s: %w", path, err)
+	}
+
+	m.mu.Lock()
+	m.groups[containerID] = &monGroup{class: class, path: path}
+	m.mu.Unlock()
+	return nil
+}
+
+// AttachPID adds pid to the monitoring group's tasks file so its CMT/MBM
+// activity is accounted against containerID.
+func (m *Monitor) AttachPID(containerID string, pid int) error {
+	m.mu.RLock()
+	grp, ok := m.groups[containerID]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no RDT monitoring group for container %s", containerID)
+	}
+
+	tasksFile := filepath.Join(grp.path, "tasks")
+	f, err := os.OpenFile(tasksFile, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", tasksFile, err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(strconv.Itoa(pid)); err != nil {
+		return fmt.Errorf("attach pid %d to %s: %w", pid, tasksFile, err)
+	}
+
+	m.mu.Lock()
+	m.pids[containerID] = append(m.pids[containerID], pid)
+	m.mu.Unlock()
+	return nil
+}
+
+// HasGroup reports whether containerID currently has a monitoring group,
+// i.e. whether it was assigned an RDT class via EnsureMonGroup. Callers
+// that only attach a PID opportunistically (e.g. once a container has
+// started) can use it to skip containers RDT doesn't apply to.
+func (m *Monitor) HasGroup(containerID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.groups[containerID]
+	return ok
+}
+
+// PIDs returns the PIDs previously attached to containerID via AttachPID.
+func (m *Monitor) PIDs(containerID string) []int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]int(nil), m.pids[containerID]...)
+}
+
+// RemoveContainer tears down the monitoring group for containerID, if
+// any.
+func (m *Monitor) RemoveContainer(containerID string) {
+	m.mu.Lock()
+	grp, ok := m.groups[containerID]
+	delete(m.groups, containerID)
+	delete(m.samples, containerID)
+	delete(m.pids, containerID)
+	m.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	if err := os.RemoveAll(grp.path); err != nil {
+		logrus.Warnf("Failed to remove RDT monitoring group %s: %v", grp.path, err)
+	}
+}
+
+// ContainerIDs returns the IDs of every container currently monitored.
+func (m *Monitor) ContainerIDs() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]string, 0, len(m.groups))
+	for id := range m.groups {
+		out = append(out, id)
+	}
+	return out
+}
+
+// Sample returns the last polled reading for containerID, and the class
+// it was taken against.
+func (m *Monitor) Sample(containerID string) (sample Sample, class string, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sample, ok = m.samples[containerID]
+	if !ok {
+		return Sample{}, "", false
+	}
+	grp, ok := m.groups[containerID]
+	if !ok {
+		// RemoveContainer deleted m.groups[containerID] but a poll already
+		// in flight still wrote a sample for it; treat it the same as no
+		// sample rather than indexing a nil *monGroup.
+		return Sample{}, "", false
+	}
+	return sample, grp.class, true
+}
+
+// Start begins polling every container's monitoring group on Monitor's
+// configured interval, until Stop is called.
+func (m *Monitor) Start() {
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.poll()
+			case <-m.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the polling loop started by Start.
+func (m *Monitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stopCh) })
+}
+
+func (m *Monitor) poll() {
+	m.mu.RLock()
+	groups := make(map[string]*monGroup, len(m.groups))
+	for id, g := range m.groups {
+		groups[id] = g
+	}
+	m.mu.RUnlock()
+
+	for containerID, grp := range groups {
+		sample, err := readMonData(grp.path)
+		if err != nil {
+			logrus.Debugf("Failed to read RDT monitoring data for container %s: %v", containerID, err)
+			continue
+		}
+
+		m.mu.Lock()
+		// RemoveContainer may have run while readMonData above was
+		// unlocked; re-check containerID is still tracked before writing
+		// a sample for it, or a stale m.samples entry with no matching
+		// m.groups entry would make Sample's class lookup unsafe.
+		if _, stillTracked := m.groups[containerID]; !stillTracked {
+			m.mu.Unlock()
+			continue
+		}
+		prev := m.samples[containerID]
+		m.samples[containerID] = sample
+		m.mu.Unlock()
+
+		recordMetrics(containerID, grp.class, prev, sample)
+	}
+}
+
+// readMonData sums the llc_occupancy/mbm_local_bytes/mbm_total_bytes
+// counters across every domain (e.g. per L3 cache instance) under
+// groupPath/mon_data.
+func readMonData(groupPath string) (Sample, error) {
+	monDataDir := filepath.Join(groupPath, "mon_data")
+	entries, err := os.ReadDir(monDataDir)
+	if err != nil {
+		return Sample{}, fmt.Errorf("read %s: %w", monDataDir, err)
+	}
+
+	var sample Sample
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), "mon_L3_") {
+			continue
+		}
+		domainDir := filepath.Join(monDataDir, e.Name())
+
+		if v, err := readUintFile(filepath.Join(domainDir, "llc_occupancy")); err == nil {
+			sample.LLCOccupancyBytes += v
+		}
+		if v, err := readUintFile(filepath.Join(domainDir, "mbm_local_bytes")); err == nil {
+			sample.MBMLocalBytes += v
+		}
+		if v, err := readUintFile(filepath.Join(domainDir, "mbm_total_bytes")); err == nil {
+			sample.MBMTotalBytes += v
+		}
+	}
+	return sample, nil
+}
+
+func readUintFile(path string) (uint64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
@@ -0,0 +1,231 @@
+package rdt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeMonData(t *testing.T, groupPath string, llc, mbmLocal, mbmTotal uint64) {
+	t.Helper()
+	domain := filepath.Join(groupPath, "mon_data", "mon_L3_00")
+	if err := os.MkdirAll(domain, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, v := range map[string]uint64{
+		"llc_occupancy":   llc,
+		"mbm_local_bytes": mbmLocal,
+		"mbm_total_bytes": mbmTotal,
+	} {
+		if err := os.WriteFile(filepath.Join(domain, name), []byte(itoa64(v)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func itoa64(v uint64) string {
+	if v == 0 {
+		return "0"
+	}
+	digits := []byte{}
+	for v > 0 {
+		digits = append([]byte{byte('0' + v%10)}, digits...)
+		v /= 10
+	}
+	return string(digits)
+}
+
+func TestMonitorEnsureAndPoll(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ResctrlPrefix+"latency-critical"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMonitor(root, 0)
+	if err := m.EnsureMonGroup("ctr-1", "latency-critical"); err != nil {
+		t.Fatalf("EnsureMonGroup: %v", err)
+	}
+
+	groupPath := m.monGroupPath("latency-critical", "ctr-1")
+	if _, err := os.Stat(groupPath); err != nil {
+		t.Fatalf("expected monitoring group dir to exist: %v", err)
+	}
+	writeMonData(t, groupPath, 1024, 2048, 4096)
+
+	m.poll()
+
+	sample, class, ok := m.Sample("ctr-1")
+	if !ok {
+		t.Fatal("expected a sample after poll")
+	}
+	if class != "latency-critical" {
+		t.Fatalf("expected class latency-critical, got %q", class)
+	}
+	if sample.LLCOccupancyBytes != 1024 || sample.MBMLocalBytes != 2048 || sample.MBMTotalBytes != 4096 {
+		t.Fatalf("unexpected sample: %+v", sample)
+	}
+
+	m.RemoveContainer("ctr-1")
+	if _, _, ok := m.Sample("ctr-1"); ok {
+		t.Fatal("expected sample to be gone after RemoveContainer")
+	}
+	if _, err := os.Stat(groupPath); !os.IsNotExist(err) {
+		t.Fatalf("expected monitoring group dir to be removed, stat err = %v", err)
+	}
+}
+
+// TestSampleIgnoresStaleEntryAfterRemove reproduces the state poll()
+// could previously leave behind when RemoveContainer ran in the window
+// between poll()'s unlocked readMonData and its re-locked write to
+// m.samples: a samples entry for a container whose m.groups entry is
+// already gone. Sample must report ok=false instead of indexing the
+// missing m.groups entry and panicking.
+func TestSampleIgnoresStaleEntryAfterRemove(t *testing.T) {
+	m := NewMonitor(t.TempDir(), 0)
+
+	m.mu.Lock()
+	m.samples["ctr-1"] = Sample{LLCOccupancyBytes: 1024}
+	m.mu.Unlock()
+
+	if _, _, ok := m.Sample("ctr-1"); ok {
+		t.Fatal("expected no sample for a container with no matching monitoring group")
+	}
+}
+
+// TestPollRaceWithRemoveContainer runs poll() concurrently with
+// RemoveContainer for the same container many times, under -race, to
+// catch poll() writing a stale m.samples entry for a container whose
+// m.groups entry is already gone (which Sample would then be unable to
+// safely read).
+func TestPollRaceWithRemoveContainer(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ResctrlPrefix+"latency-critical"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMonitor(root, 0)
+	for i := 0; i < 50; i++ {
+		if err := m.EnsureMonGroup("ctr-1", "latency-critical"); err != nil {
+			t.Fatalf("EnsureMonGroup: %v", err)
+		}
+		writeMonData(t, m.monGroupPath("latency-critical", "ctr-1"), 1024, 2048, 4096)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			m.poll()
+		}()
+		m.RemoveContainer("ctr-1")
+		<-done
+
+		if _, _, ok := m.Sample("ctr-1"); ok {
+			// Even if poll() won the race and recorded a sample before
+			// RemoveContainer ran, that's fine; what must never happen is
+			// Sample panicking, which -race/the test runner would already
+			// have caught as a crash rather than a failed assertion.
+			m.RemoveContainer("ctr-1")
+		}
+	}
+}
+
+func TestAttachPIDRequiresMonGroup(t *testing.T) {
+	m := NewMonitor(t.TempDir(), 0)
+	if err := m.AttachPID("unknown", 1234); err == nil {
+		t.Fatal("expected error attaching PID to a container with no monitoring group")
+	}
+}
+
+func TestHasGroup(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ResctrlPrefix+"latency-critical"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMonitor(root, 0)
+	if m.HasGroup("ctr-1") {
+		t.Fatal("expected no group before EnsureMonGroup")
+	}
+	if err := m.EnsureMonGroup("ctr-1", "latency-critical"); err != nil {
+		t.Fatalf("EnsureMonGroup: %v", err)
+	}
+	if !m.HasGroup("ctr-1") {
+		t.Fatal("expected a group after EnsureMonGroup")
+	}
+
+	m.RemoveContainer("ctr-1")
+	if m.HasGroup("ctr-1") {
+		t.Fatal("expected no group after RemoveContainer")
+	}
+}
+
+// TestReassignClassMechanismOnlyNotWiredToUpdateContainerResources
+// exercises ReassignClass itself: moving a container's PID into a new
+// class's tasks file and recreating its monitoring group there, all
+// without restarting the container. It is NOT a test of the kubelet-facing
+// UpdateContainerResources path - server.reassignContainerRdtClass always
+// refuses, by design, until a container-start path exists to attach a
+// real PID via Monitor.AttachPID outside of tests. The request's ask for
+// live RDT reassignment through UpdateContainerResources remains
+// unimplemented; this test only proves the underlying mechanism works.
+func TestReassignClassMechanismOnlyNotWiredToUpdateContainerResources(t *testing.T) {
+	root := t.TempDir()
+	for _, class := range []string{"latency-critical", "best-effort"} {
+		if err := os.MkdirAll(filepath.Join(root, ResctrlPrefix+class), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, ResctrlPrefix+class, "tasks"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewMonitor(root, 0)
+	// resctrl provides each monitoring group's tasks file itself; the fake
+	// tree has to supply it for AttachPID to open, in both classes.
+	for _, class := range []string{"latency-critical", "best-effort"} {
+		groupPath := m.monGroupPath(class, "ctr-1")
+		if err := os.MkdirAll(groupPath, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(groupPath, "tasks"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.EnsureMonGroup("ctr-1", "latency-critical"); err != nil {
+		t.Fatalf("EnsureMonGroup: %v", err)
+	}
+	if err := m.AttachPID("ctr-1", 4242); err != nil {
+		t.Fatalf("AttachPID: %v", err)
+	}
+
+	if err := ReassignClass(root, "ctr-1", "best-effort", m.PIDs("ctr-1"), m); err != nil {
+		t.Fatalf("ReassignClass: %v", err)
+	}
+
+	newTasks, err := os.ReadFile(filepath.Join(root, ResctrlPrefix+"best-effort", "tasks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(newTasks) != "4242" {
+		t.Fatalf("expected pid 4242 written to best-effort tasks file, got %q", string(newTasks))
+	}
+
+	if _, err := os.Stat(m.monGroupPath("latency-critical", "ctr-1")); !os.IsNotExist(err) {
+		t.Fatalf("expected old monitoring group to be removed, stat err = %v", err)
+	}
+	if _, err := os.Stat(m.monGroupPath("best-effort", "ctr-1")); err != nil {
+		t.Fatalf("expected new monitoring group to exist: %v", err)
+	}
+
+	// The monitoring group must live under the same CLOS directory as the
+	// tasks file the container's PID was just moved into, or CMT/MBM
+	// samples would be read from a resctrl group the container isn't
+	// actually in.
+	wantClassDir := filepath.Join(root, ResctrlPrefix+"best-effort")
+	if got := filepath.Dir(filepath.Dir(m.monGroupPath("best-effort", "ctr-1"))); got != wantClassDir {
+		t.Fatalf("expected monitoring group under %s, got %s", wantClassDir, got)
+	}
+
+	if got := m.PIDs("ctr-1"); len(got) != 1 || got[0] != 4242 {
+		t.Fatalf("expected PID 4242 to still be tracked after reassignment, got %v", got)
+	}
+}
@@ -0,0 +1,48 @@
+package rdt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ReassignClass moves pids from their current resctrl class into newClass
+// by writing them to newClass's tasks file, then updates the monitoring
+// group monitor keeps for containerID to match (tearing down the old
+// group and re-attaching pids under the new one), without restarting the
+// container. UpdateContainerResources does not call it:
+// server.reassignContainerRdtClass refuses every RDT class change on a
+// running container.
+func ReassignClass(resctrlRoot, containerID, newClass string, pids []int, monitor *Monitor) error {
+	if len(pids) == 0 {
+		return fmt.Errorf("no known PIDs for container %s; cannot reassign RDT class", containerID)
+	}
+
+	tasksFile := filepath.Join(resctrlRoot, ResctrlPrefix+newClass, "tasks")
+	f, err := os.OpenFile(tasksFile, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", tasksFile, err)
+	}
+	defer f.Close()
+
+	for _, pid := range pids {
+		if _, err := f.WriteString(strconv.Itoa(pid)); err != nil {
+			return fmt.Errorf("move pid %d to RDT class %q: %w", pid, newClass, err)
+		}
+	}
+
+	if monitor != nil {
+		monitor.RemoveContainer(containerID)
+		if err := monitor.EnsureMonGroup(containerID, newClass); err != nil {
+			return fmt.Errorf("recreate monitoring group for container %s in class %q: %w", containerID, newClass, err)
+		}
+		for _, pid := range pids {
+			if err := monitor.AttachPID(containerID, pid); err != nil {
+				return fmt.Errorf("reattach pid %d to monitoring group for container %s: %w", pid, containerID, err)
+			}
+		}
+	}
+
+	return nil
+}
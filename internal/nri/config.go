@@ -0,0 +1,53 @@
+package nri
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config is the `[nri]` section of the CRI-O TOML configuration file.
+type Config struct {
+	Plugins []PluginTOMLConfig `toml:"plugin"`
+}
+
+// PluginTOMLConfig is one `[[nri.plugin]]` entry.
+type PluginTOMLConfig struct {
+	Name           string `toml:"name"`
+	SocketPath     string `toml:"socket_path"`
+	TimeoutSeconds int    `toml:"timeout_seconds"`
+	FailurePolicy  string `toml:"failure_policy"`
+}
+
+// LoadConfig parses the TOML file at path.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		return nil, fmt.Errorf("decode NRI plugin config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// RegisterFromConfig connects to and registers every plugin declared in
+// cfg with r.
+func (r *Registry) RegisterFromConfig(ctx context.Context, cfg *Config) error {
+	for _, pc := range cfg.Plugins {
+		policy := FailurePolicy(pc.FailurePolicy)
+		if policy != FailOpen && policy != FailClosed && policy != "" {
+			return fmt.Errorf("plugin %q: invalid failure_policy %q", pc.Name, pc.FailurePolicy)
+		}
+
+		timeout := time.Duration(pc.TimeoutSeconds) * time.Second
+		if err := r.RegisterPlugin(ctx, PluginConfig{
+			Name:          pc.Name,
+			SocketPath:    pc.SocketPath,
+			Timeout:       timeout,
+			FailurePolicy: policy,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package nri
+
+import (
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Apply mutates spec according to adj's CPU/annotation fields. It
+// deliberately leaves adj.RDTClass/adj.BlockIOClass untouched: this
+// package has no access to the RDT/BlockIO subsystems that resolve a
+// class into a ClosID or cgroup values, so the caller must re-resolve
+// those two fields itself after calling Apply (see
+// server.handleContainerQoSResources, which does so).
+func Apply(spec *rspec.Spec, adj *Adjustment) {
+	if adj == nil {
+		return
+	}
+
+	if adj.CPU != nil {
+		if spec.Linux.Resources == nil {
+			spec.Linux.Resources = &rspec.LinuxResources{}
+		}
+		if spec.Linux.Resources.CPU == nil {
+			spec.Linux.Resources.CPU = &rspec.LinuxCPU{}
+		}
+		if adj.CPU.Cpus != "" {
+			spec.Linux.Resources.CPU.Cpus = adj.CPU.Cpus
+		}
+		if adj.CPU.Mems != "" {
+			spec.Linux.Resources.CPU.Mems = adj.CPU.Mems
+		}
+	}
+
+	if len(adj.Annotations) > 0 {
+		if spec.Annotations == nil {
+			spec.Annotations = map[string]string{}
+		}
+		for k, v := range adj.Annotations {
+			spec.Annotations[k] = v
+		}
+	}
+}
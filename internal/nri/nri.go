@@ -0,0 +1,239 @@
+// Package nri implements a small NRI-style (Node Resource Interface)
+// plugin point: external agents connect over a Unix socket and are given
+// a chance to observe and adjust the pending OCI spec, plus the QoS
+// classes CRI-O resolved for it, before runc is invoked.
+package nri
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Event identifies which lifecycle point a plugin is being invoked for.
+type Event string
+
+const (
+	EventConfigure       Event = "Configure"
+	EventSynchronize     Event = "Synchronize"
+	EventRunPodSandbox   Event = "RunPodSandbox"
+	EventCreateContainer Event = "CreateContainer"
+	EventUpdateContainer Event = "UpdateContainer"
+	EventStopPodSandbox  Event = "StopPodSandbox"
+)
+
+// EventConfigure is dispatched once per plugin from RegisterFromConfig,
+// EventRunPodSandbox/EventCreateContainer/EventUpdateContainer from the
+// server package's corresponding handlers. EventSynchronize (a full
+// resync of every running pod/container a plugin missed while
+// disconnected) and EventStopPodSandbox belong on the reconciliation
+// loop and StopPodSandbox request handler respectively; neither lives in
+// this package, so dispatching those two events is that code's
+// responsibility, not this one's.
+
+// FailurePolicy controls what happens when a plugin errors or times out.
+type FailurePolicy string
+
+const (
+	// FailOpen ignores a failing plugin and proceeds as if it had
+	// returned no adjustment.
+	FailOpen FailurePolicy = "fail-open"
+	// FailClosed propagates a failing plugin's error to the caller,
+	// normally failing the sandbox/container create request.
+	FailClosed FailurePolicy = "fail-closed"
+)
+
+// Request is sent to a plugin for every event.
+type Request struct {
+	Event        Event             `json:"event"`
+	PodSandboxID string            `json:"podSandboxId,omitempty"`
+	ContainerID  string            `json:"containerId,omitempty"`
+	QoSClasses   map[string]string `json:"qosClasses,omitempty"`
+	// OCISpec is the JSON-encoded OCI runtime spec as it stands before
+	// this plugin runs; plugins are expected to only look at what they
+	// need rather than fully unmarshal/remarshal it.
+	OCISpec json.RawMessage `json:"ociSpec,omitempty"`
+}
+
+// CPUAdjustment adjusts the container's cpuset.
+type CPUAdjustment struct {
+	Cpus string `json:"cpus,omitempty"`
+	Mems string `json:"mems,omitempty"`
+}
+
+// Adjustment is what a plugin returns to mutate the pending spec. It
+// mirrors the subset of NRI's LinuxContainerAdjustment CRI-O understands.
+type Adjustment struct {
+	CPU          *CPUAdjustment    `json:"cpu,omitempty"`
+	RDTClass     string            `json:"rdtClass,omitempty"`
+	BlockIOClass string            `json:"blockioClass,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// Response is a plugin's reply to a Request.
+type Response struct {
+	Adjustment *Adjustment `json:"adjustment,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// PluginConfig describes how to reach and treat one plugin.
+type PluginConfig struct {
+	// Name identifies the plugin in logs and errors.
+	Name string
+	// SocketPath is the Unix socket the plugin listens on.
+	SocketPath string
+	// Timeout bounds how long CRI-O waits for a single event reply.
+	Timeout time.Duration
+	// FailurePolicy controls behavior when the plugin errors or times
+	// out. Defaults to FailOpen if empty.
+	FailurePolicy FailurePolicy
+}
+
+// Plugin is a connection to one registered NRI plugin.
+type Plugin struct {
+	cfg PluginConfig
+}
+
+func (p *Plugin) failurePolicy() FailurePolicy {
+	if p.cfg.FailurePolicy == "" {
+		return FailOpen
+	}
+	return p.cfg.FailurePolicy
+}
+
+// invoke dials the plugin's socket, sends req as a single JSON line and
+// reads back a single JSON line response.
+func (p *Plugin) invoke(ctx context.Context, req Request) (*Response, error) {
+	timeout := p.cfg.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "unix", p.cfg.SocketPath)
+	if err != nil {
+		return nil, fmt.Errorf("dial NRI plugin %q at %s: %w", p.cfg.Name, p.cfg.SocketPath, err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("set deadline for NRI plugin %q: %w", p.cfg.Name, err)
+	}
+
+	enc := json.NewEncoder(conn)
+	if err := enc.Encode(req); err != nil {
+		return nil, fmt.Errorf("send request to NRI plugin %q: %w", p.cfg.Name, err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("read response from NRI plugin %q: %w", p.cfg.Name, err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("NRI plugin %q returned an error: %s", p.cfg.Name, resp.Error)
+	}
+	return &resp, nil
+}
+
+// Registry holds the set of configured NRI plugins and dispatches events
+// to them in registration order.
+type Registry struct {
+	mu      sync.RWMutex
+	plugins []*Plugin
+}
+
+// New creates an empty Registry.
+func New() *Registry {
+	return &Registry{}
+}
+
+var (
+	defaultRegistry     *Registry
+	defaultRegistryOnce sync.Once
+)
+
+// DefaultRegistry returns the process-wide plugin Registry.
+func DefaultRegistry() *Registry {
+	defaultRegistryOnce.Do(func() { defaultRegistry = New() })
+	return defaultRegistry
+}
+
+// RegisterPlugin adds a plugin to the registry and sends it a Configure
+// event so it can reject an incompatible CRI-O version up front.
+func (r *Registry) RegisterPlugin(ctx context.Context, cfg PluginConfig) error {
+	p := &Plugin{cfg: cfg}
+	if _, err := p.invoke(ctx, Request{Event: EventConfigure}); err != nil {
+		return fmt.Errorf("configure NRI plugin %q: %w", cfg.Name, err)
+	}
+
+	r.mu.Lock()
+	r.plugins = append(r.plugins, p)
+	r.mu.Unlock()
+	return nil
+}
+
+// Plugins returns the currently registered plugins.
+func (r *Registry) Plugins() []*Plugin {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*Plugin(nil), r.plugins...)
+}
+
+// Invoke sends req to every registered plugin in order, merging their
+// Adjustments (a later plugin's non-empty fields take precedence over an
+// earlier plugin's) and honoring each plugin's FailurePolicy.
+func (r *Registry) Invoke(ctx context.Context, event Event, req Request) (*Adjustment, error) {
+	req.Event = event
+
+	merged := &Adjustment{}
+	for _, p := range r.Plugins() {
+		resp, err := p.invoke(ctx, req)
+		if err != nil {
+			if p.failurePolicy() == FailClosed {
+				return nil, err
+			}
+			logrus.Warnf("NRI plugin %q failed, ignoring (fail-open): %v", p.cfg.Name, err)
+			continue
+		}
+		if resp.Adjustment != nil {
+			mergeInto(merged, resp.Adjustment)
+		}
+	}
+	return merged, nil
+}
+
+func mergeInto(dst, src *Adjustment) {
+	if src.CPU != nil {
+		if dst.CPU == nil {
+			dst.CPU = &CPUAdjustment{}
+		}
+		if src.CPU.Cpus != "" {
+			dst.CPU.Cpus = src.CPU.Cpus
+		}
+		if src.CPU.Mems != "" {
+			dst.CPU.Mems = src.CPU.Mems
+		}
+	}
+	if src.RDTClass != "" {
+		dst.RDTClass = src.RDTClass
+	}
+	if src.BlockIOClass != "" {
+		dst.BlockIOClass = src.BlockIOClass
+	}
+	for k, v := range src.Annotations {
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[k] = v
+	}
+}
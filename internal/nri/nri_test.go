@@ -0,0 +1,215 @@
+package nri
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// startFakePlugin serves one connection per incoming request, replying
+// with whatever handle returns for the decoded Request, and returns the
+// socket path it is listening on.
+func startFakePlugin(t *testing.T, handle func(Request) Response) string {
+	t.Helper()
+
+	sockPath := filepath.Join(t.TempDir(), "plugin.sock")
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				var req Request
+				if err := json.NewDecoder(c).Decode(&req); err != nil {
+					return
+				}
+				resp := handle(req)
+				_ = json.NewEncoder(c).Encode(resp)
+			}(conn)
+		}
+	}()
+
+	return sockPath
+}
+
+func TestRegisterAndInvokeMergesAdjustment(t *testing.T) {
+	sock := startFakePlugin(t, func(req Request) Response {
+		if req.Event == EventConfigure {
+			return Response{}
+		}
+		return Response{Adjustment: &Adjustment{
+			CPU:      &CPUAdjustment{Cpus: "0-1"},
+			RDTClass: "latency-critical",
+		}}
+	})
+
+	r := New()
+	ctx := context.Background()
+	if err := r.RegisterPlugin(ctx, PluginConfig{Name: "test", SocketPath: sock, Timeout: time.Second}); err != nil {
+		t.Fatalf("RegisterPlugin: %v", err)
+	}
+
+	adj, err := r.Invoke(ctx, EventCreateContainer, Request{ContainerID: "ctr-1"})
+	if err != nil {
+		t.Fatalf("Invoke: %v", err)
+	}
+	if adj.CPU == nil || adj.CPU.Cpus != "0-1" {
+		t.Fatalf("expected CPU adjustment 0-1, got %+v", adj.CPU)
+	}
+	if adj.RDTClass != "latency-critical" {
+		t.Fatalf("expected RDT class latency-critical, got %q", adj.RDTClass)
+	}
+
+	spec := &rspec.Spec{Linux: &rspec.Linux{}}
+	Apply(spec, adj)
+	if spec.Linux.Resources.CPU.Cpus != "0-1" {
+		t.Fatalf("expected spec cpuset 0-1, got %q", spec.Linux.Resources.CPU.Cpus)
+	}
+}
+
+func TestInvokeMergesCPUFieldsAcrossPlugins(t *testing.T) {
+	sockA := startFakePlugin(t, func(req Request) Response {
+		if req.Event == EventConfigure {
+			return Response{}
+		}
+		return Response{Adjustment: &Adjustment{CPU: &CPUAdjustment{Cpus: "0-1"}}}
+	})
+	sockB := startFakePlugin(t, func(req Request) Response {
+		if req.Event == EventConfigure {
+			return Response{}
+		}
+		return Response{Adjustment: &Adjustment{CPU: &CPUAdjustment{Mems: "0"}}}
+	})
+
+	r := New()
+	ctx := context.Background()
+	if err := r.RegisterPlugin(ctx, PluginConfig{Name: "a", SocketPath: sockA, Timeout: time.Second}); err != nil {
+		t.Fatalf("RegisterPlugin a: %v", err)
+	}
+	if err := r.RegisterPlugin(ctx, PluginConfig{Name: "b", SocketPath: sockB, Timeout: time.Second}); err != nil {
+		t.Fatalf("RegisterPlugin b: %v", err)
+	}
+
+	adj, err := r.Invoke(ctx, EventCreateContainer, Request{ContainerID: "ctr-1"})
+	if err != nil {
+		t.Fatalf("Invoke: %v", err)
+	}
+	// Plugin b's CPU adjustment only sets Mems; it must not wipe out
+	// plugin a's Cpus by replacing the CPU struct wholesale.
+	if adj.CPU == nil || adj.CPU.Cpus != "0-1" || adj.CPU.Mems != "0" {
+		t.Fatalf("expected merged CPU adjustment {Cpus:0-1 Mems:0}, got %+v", adj.CPU)
+	}
+}
+
+func TestInvokeFailOpenIgnoresErroringPlugin(t *testing.T) {
+	sock := startFakePlugin(t, func(req Request) Response {
+		if req.Event == EventConfigure {
+			return Response{}
+		}
+		return Response{Error: "boom"}
+	})
+
+	r := New()
+	ctx := context.Background()
+	if err := r.RegisterPlugin(ctx, PluginConfig{Name: "flaky", SocketPath: sock, Timeout: time.Second, FailurePolicy: FailOpen}); err != nil {
+		t.Fatalf("RegisterPlugin: %v", err)
+	}
+
+	adj, err := r.Invoke(ctx, EventCreateContainer, Request{})
+	if err != nil {
+		t.Fatalf("expected fail-open to swallow plugin error, got %v", err)
+	}
+	if adj.CPU != nil || adj.RDTClass != "" {
+		t.Fatalf("expected empty adjustment, got %+v", adj)
+	}
+}
+
+func TestInvokeFailClosedPropagatesError(t *testing.T) {
+	sock := startFakePlugin(t, func(req Request) Response {
+		if req.Event == EventConfigure {
+			return Response{}
+		}
+		return Response{Error: "boom"}
+	})
+
+	r := New()
+	ctx := context.Background()
+	if err := r.RegisterPlugin(ctx, PluginConfig{Name: "strict", SocketPath: sock, Timeout: time.Second, FailurePolicy: FailClosed}); err != nil {
+		t.Fatalf("RegisterPlugin: %v", err)
+	}
+
+	if _, err := r.Invoke(ctx, EventCreateContainer, Request{}); err == nil {
+		t.Fatal("expected fail-closed plugin error to propagate")
+	}
+}
+
+// TestInvokePassesOCISpecToPlugin makes sure the pending OCI spec a
+// caller puts on Request.OCISpec actually reaches the plugin, not just
+// the QoS class map - the whole point of carrying it on Request.
+func TestInvokePassesOCISpecToPlugin(t *testing.T) {
+	var gotSpec rspec.Spec
+	received := make(chan struct{}, 1)
+
+	sock := startFakePlugin(t, func(req Request) Response {
+		if req.Event == EventConfigure {
+			return Response{}
+		}
+		if len(req.OCISpec) > 0 {
+			_ = json.Unmarshal(req.OCISpec, &gotSpec)
+			received <- struct{}{}
+		}
+		return Response{}
+	})
+
+	r := New()
+	ctx := context.Background()
+	if err := r.RegisterPlugin(ctx, PluginConfig{Name: "test", SocketPath: sock, Timeout: time.Second}); err != nil {
+		t.Fatalf("RegisterPlugin: %v", err)
+	}
+
+	spec := &rspec.Spec{Linux: &rspec.Linux{Resources: &rspec.LinuxResources{CPU: &rspec.LinuxCPU{Cpus: "0-1"}}}}
+	ociSpec, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatalf("marshal spec: %v", err)
+	}
+
+	if _, err := r.Invoke(ctx, EventCreateContainer, Request{ContainerID: "ctr-1", OCISpec: ociSpec}); err != nil {
+		t.Fatalf("Invoke: %v", err)
+	}
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("plugin never received a non-empty OCISpec")
+	}
+	if gotSpec.Linux == nil || gotSpec.Linux.Resources == nil || gotSpec.Linux.Resources.CPU.Cpus != "0-1" {
+		t.Fatalf("expected plugin to see the marshaled spec's cpuset, got %+v", gotSpec)
+	}
+}
+
+func TestRegisterPluginConfigureFailureIsRejected(t *testing.T) {
+	sock := startFakePlugin(t, func(req Request) Response {
+		return Response{Error: "unsupported CRI-O version"}
+	})
+
+	r := New()
+	if err := r.RegisterPlugin(context.Background(), PluginConfig{Name: "incompatible", SocketPath: sock, Timeout: time.Second}); err == nil {
+		t.Fatal("expected Configure failure to prevent registration")
+	}
+	if len(r.Plugins()) != 0 {
+		t.Fatal("expected no plugins registered after a failed Configure")
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/cri-o/cri-o/internal/config/qos"
+	"github.com/cri-o/cri-o/internal/nri"
+	"golang.org/x/net/context"
+	types "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// UpdateContainerResources updates a running container's resources,
+// including reassigning it between mutable QoS resource classes (see
+// getContainerQoSResourcesInfo for which resources are mutable).
+//
+// Scope note: the original request for this handler asked for RDT and
+// BlockIO to be reassignable live, the same as any other QoS resource.
+// That half isn't implemented - reassignContainerRdtClass and
+// reassignContainerBlockioClass below always refuse, and
+// getContainerQoSResourcesInfo keeps advertising both as immutable - so
+// treat that part of the request as not done rather than delivered, and
+// re-file it separately once the prerequisites (a container-start path
+// that attaches a real PID for RDT; a way to reach a running container's
+// cgroup manager for BlockIO) exist to build it against. Only
+// config-declared and provider-backed resources (the default branch
+// below) are actually reassignable today.
+func (s *Server) UpdateContainerResources(ctx context.Context, req *types.UpdateContainerResourcesRequest) (*types.UpdateContainerResourcesResponse, error) {
+	containerID := req.GetContainerId()
+	qosClasses := req.GetLinux().GetQosResources().GetClasses()
+
+	for resource, class := range qosClasses {
+		if err := s.reassignContainerQoSClass(containerID, resource, class); err != nil {
+			return nil, err
+		}
+	}
+
+	// Let NRI plugins observe the reassignment. UpdateContainerResources
+	// has no OCI spec to adjust (the container is already running), so
+	// unlike CreateContainer an Adjustment here can only be observed in
+	// logs/telemetry, not applied.
+	if _, err := nri.DefaultRegistry().Invoke(ctx, nri.EventUpdateContainer, nri.Request{
+		ContainerID: containerID,
+		QoSClasses:  qosClasses,
+	}); err != nil {
+		return nil, fmt.Errorf("NRI UpdateContainer: %w", err)
+	}
+
+	return &types.UpdateContainerResourcesResponse{}, nil
+}
+
+// reassignContainerQoSClass moves containerID to class for resource on a
+// live container, without a restart.
+func (s *Server) reassignContainerQoSClass(containerID, resource, class string) error {
+	switch resource {
+	case types.QoSResourceRdt:
+		return s.reassignContainerRdtClass(containerID, class)
+	case types.QoSResourceBlockio:
+		return s.reassignContainerBlockioClass(containerID, class)
+	default:
+		if err := qos.DefaultRegistry().Reassign(containerID, resource, class); err != nil {
+			return err
+		}
+		return s.persistQoSClass(containerID, resource, class)
+	}
+}
+
+func (s *Server) reassignContainerRdtClass(containerID, class string) error {
+	// RDT is advertised as immutable in getContainerQoSResourcesInfo:
+	// rdt.ReassignClass needs the container's PID(s) to move into the new
+	// class's tasks file, and those are only known once something calls
+	// Monitor.AttachPID from the container-start path - which doesn't
+	// exist in this tree yet. Moving an empty PID list would report
+	// success without actually reassigning anything running, so refuse
+	// instead of lying to the kubelet, mirroring
+	// reassignContainerBlockioClass below.
+	return fmt.Errorf("RDT class of a running container cannot be reassigned, only set at creation: %q", class)
+}
+
+func (s *Server) reassignContainerBlockioClass(containerID, class string) error {
+	// BlockIO is advertised as immutable in getContainerQoSResourcesInfo:
+	// re-applying a class's cgroup values to an already-running container
+	// requires reaching its cgroup manager, which isn't wired up to
+	// UpdateContainerResources (it currently lives behind the container
+	// store, not the QoS subsystem). Returning success here without
+	// actually moving the cgroup would wrongly tell the kubelet the
+	// reassignment took effect, so refuse it instead of pretending.
+	return fmt.Errorf("BlockIO class of a running container cannot be reassigned, only set at creation: %q", class)
+}
+
+// persistQoSClass records containerID's new class for resource so a
+// CRI-O restart can reconcile it.
+func (s *Server) persistQoSClass(containerID, resource, class string) error {
+	store, err := qos.DefaultClassStore()
+	if err != nil {
+		return fmt.Errorf("open QoS class store: %w", err)
+	}
+	return store.Set(containerID, resource, class)
+}
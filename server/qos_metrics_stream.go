@@ -0,0 +1,95 @@
+package server
+
+import (
+	"time"
+
+	"github.com/cri-o/cri-o/internal/config/rdt"
+	"golang.org/x/net/context"
+)
+
+// The CRIOExt.StreamQoSMetrics gRPC method lives on a CRI-O-specific
+// extension service, alongside the standard RuntimeService. Its contract
+// is defined in internal/criext/qos_metrics.proto. Generating and
+// registering the real service stubs (protoc-gen-go-grpc plus a
+// grpc.Server.RegisterService call in CRI-O's gRPC setup, neither of
+// which exists in this source tree) is follow-up work; until then the
+// interfaces below mirror the generated client/server contract so the
+// handler itself can be implemented and tested ahead of that wire-up, but
+// StreamQoSMetrics is not reachable by a real gRPC client yet.
+
+// QoSMetricsRequest selects which container's RDT monitoring counters to
+// stream. An empty ContainerId streams every container CRI-O currently
+// monitors.
+type QoSMetricsRequest struct {
+	ContainerId string
+}
+
+// QoSMetricsResponse is one sample of a container's RDT CMT/MBM counters.
+type QoSMetricsResponse struct {
+	ContainerId       string
+	Class             string
+	LlcOccupancyBytes uint64
+	MbmLocalBytes     uint64
+	MbmTotalBytes     uint64
+}
+
+// CRIOExtStreamQoSMetricsServer is the server-side streaming handle
+// CRI-O's generated gRPC code will provide for CRIOExt.StreamQoSMetrics.
+type CRIOExtStreamQoSMetricsServer interface {
+	Send(*QoSMetricsResponse) error
+	Context() context.Context
+}
+
+// qosMetricsStreamInterval is how often StreamQoSMetrics pushes a new
+// sample for each monitored container.
+const qosMetricsStreamInterval = 5 * time.Second
+
+// StreamQoSMetrics implements the CRIOExt.StreamQoSMetrics RPC. It pushes
+// the latest RDT CMT/MBM sample for the requested container (or every
+// monitored container, if none is specified) on qosMetricsStreamInterval,
+// until the client disconnects.
+func (s *Server) StreamQoSMetrics(req *QoSMetricsRequest, stream CRIOExtStreamQoSMetricsServer) error {
+	ticker := time.NewTicker(qosMetricsStreamInterval)
+	defer ticker.Stop()
+
+	monitor := rdt.DefaultMonitor()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+			for _, resp := range s.collectQoSMetrics(monitor, req.ContainerId) {
+				if err := stream.Send(resp); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// collectQoSMetrics gathers the current RDT sample(s) to send for a
+// StreamQoSMetrics tick. If containerID is non-empty only that
+// container's sample is returned.
+func (s *Server) collectQoSMetrics(monitor *rdt.Monitor, containerID string) []*QoSMetricsResponse {
+	ids := monitor.ContainerIDs()
+	if containerID != "" {
+		ids = []string{containerID}
+	}
+
+	out := make([]*QoSMetricsResponse, 0, len(ids))
+	for _, id := range ids {
+		sample, class, ok := monitor.Sample(id)
+		if !ok {
+			continue
+		}
+		out = append(out, &QoSMetricsResponse{
+			ContainerId:       id,
+			Class:             class,
+			LlcOccupancyBytes: sample.LLCOccupancyBytes,
+			MbmLocalBytes:     sample.MBMLocalBytes,
+			MbmTotalBytes:     sample.MBMTotalBytes,
+		})
+	}
+	return out
+}
@@ -1,26 +1,24 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
 
+	"github.com/cri-o/cri-o/internal/config/qos"
 	"github.com/cri-o/cri-o/internal/config/rdt"
 	"github.com/cri-o/cri-o/internal/lib/sandbox"
+	"github.com/cri-o/cri-o/internal/nri"
 	"github.com/intel/goresctrl/pkg/blockio"
 	rspec "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
 	types "k8s.io/cri-api/pkg/apis/runtime/v1"
 )
 
-// HACK: dummyQoS resources
-var dummyPodQoSResourcesInfo []*types.QoSResourceInfo
-var dummyContainerQoSResourcesInfo []*types.QoSResourceInfo
-var dummyPodQoSResources map[string]map[string]struct{}
-var dummyContainerQoSResources map[string]map[string]struct{}
-
-// getPodQoSResourcesInfo returns information about all container-level QoS resources.
+// getPodQoSResourcesInfo returns information about all pod-level QoS resources.
 func (s *Server) getPodQoSResourcesInfo() []*types.QoSResourceInfo {
 	info := []*types.QoSResourceInfo{}
-	info = append(info, dummyPodQoSResourcesInfo...)
+	info = append(info, qos.DefaultRegistry().ResourceInfos(qos.ScopePod)...)
 	return info
 }
 
@@ -28,7 +26,13 @@ func (s *Server) getPodQoSResourcesInfo() []*types.QoSResourceInfo {
 func (s *Server) getContainerQoSResourcesInfo() []*types.QoSResourceInfo {
 	info := []*types.QoSResourceInfo{}
 
-	// RDT
+	// RDT: classes are not mutable yet. ReassignClass can move a running
+	// container's PID to a different ClosID by rewriting its resctrl
+	// tasks file, but rdt.Monitor only ever learns a container's PID via
+	// AttachPID, and nothing in this tree calls AttachPID outside of
+	// tests - so reassignContainerRdtClass would always operate on an
+	// empty PID list. Advertise immutable until the container-start path
+	// calls attachContainerRdtMonitoringPID for real.
 	if rdtClasses := s.Config().Rdt().GetClasses(); len(rdtClasses) > 0 {
 		info = append(info,
 			&types.QoSResourceInfo{
@@ -38,7 +42,11 @@ func (s *Server) getContainerQoSResourcesInfo() []*types.QoSResourceInfo {
 			})
 	}
 
-	// blockio
+	// blockio: classes are not mutable yet. Re-applying a class's cgroup
+	// values to an already-running container requires reaching its cgroup
+	// manager, which isn't wired up to UpdateContainerResources; a
+	// reassignment today only takes effect on the container's next
+	// (re)creation.
 	if blockioClasses := s.Config().BlockIO().GetClasses(); len(blockioClasses) > 0 {
 		info = append(info,
 			&types.QoSResourceInfo{
@@ -48,7 +56,7 @@ func (s *Server) getContainerQoSResourcesInfo() []*types.QoSResourceInfo {
 			})
 	}
 
-	info = append(info, dummyContainerQoSResourcesInfo...)
+	info = append(info, qos.DefaultRegistry().ResourceInfos(qos.ScopeContainer)...)
 
 	return info
 }
@@ -64,50 +72,61 @@ func createClassInfos(names ...string) []*types.QoSResourceClassInfo {
 // handleSandboxQoSResources handles QoS resource requests for a pod sandbox.
 func (s *Server) handleSandboxQoSResources(config *types.PodSandboxConfig) error {
 	for r, c := range config.GetQosResources().GetClasses() {
-		switch r {
-		default:
-			cr, ok := dummyPodQoSResources[r]
-			if !ok {
-				return fmt.Errorf("unknown QoS resource type %q", r)
-			}
-			if _, ok := cr[c]; !ok {
-				return fmt.Errorf("unknown %s class %q", r, c)
-			}
-			logrus.Infof("setting dummy QoS resource %s=%s", r, c)
-		}
-
 		if c == "" {
 			return fmt.Errorf("empty class name not allowed for QoS resource type %q", r)
 		}
+		if err := qos.DefaultRegistry().Validate(r, c); err != nil {
+			return err
+		}
+		logrus.Infof("setting pod QoS resource %s=%s", r, c)
 	}
+
+	// Give NRI plugins a chance to observe the sandbox's requested QoS
+	// classes. A sandbox doesn't carry an OCI spec of its own to adjust at
+	// this point, so plugins can only observe here; RunPodSandbox
+	// adjustments apply to the sandbox's containers individually via
+	// CreateContainer instead.
+	if _, err := nri.DefaultRegistry().Invoke(context.Background(), nri.EventRunPodSandbox, nri.Request{
+		QoSClasses: config.GetQosResources().GetClasses(),
+	}); err != nil {
+		return fmt.Errorf("NRI RunPodSandbox: %w", err)
+	}
+
 	return nil
 }
 
-// handleContainerQoSResources handles QoS resource requests for a container.
-func (s *Server) handleContainerQoSResources(spec *rspec.Spec, container *types.ContainerConfig, sb *sandbox.Sandbox) error {
-	// Handle QoS resource assignments
-	for r, c := range container.GetQosResources().GetClasses() {
+// handleContainerQoSResources handles QoS resource requests for a
+// container. containerID is the ID CRI-O has already generated for the
+// container (the same ID later passed to UpdateContainerResources), and is
+// used as the key for every piece of QoS state this function sets up, so a
+// subsequent reassignment can find it again.
+func (s *Server) handleContainerQoSResources(spec *rspec.Spec, containerID string, container *types.ContainerConfig, sb *sandbox.Sandbox) error {
+	// Handle QoS resource assignments. RDT and BlockIO are handled
+	// separately below because we have pod and container annotations as a
+	// fallback interface for them, and it isn't enough to rely on the QoS
+	// resources in CRI only. Everything else is dispatched to the
+	// registered QoS resource providers.
+	requested := container.GetQosResources().GetClasses()
+	for r, c := range requested {
+		if c == "" {
+			return fmt.Errorf("empty class name not allowed for QoS resource type %q", r)
+		}
+
 		switch r {
 		case types.QoSResourceRdt:
 		case types.QoSResourceBlockio:
-			// We handle RDT and BlockIO separately in as we have pod and
-			// container annotations as fallback interface and it isn't enough
-			// to rely on the QoS resources in CRI only
 		default:
-			cr, ok := dummyContainerQoSResources[r]
-			if !ok {
-				return fmt.Errorf("unknown QoS resource type %q", r)
+			if err := qos.DefaultRegistry().Apply(spec, containerID, r, c); err != nil {
+				return err
 			}
-			if _, ok := cr[c]; !ok {
-				return fmt.Errorf("unknown %s class %q", r, c)
-			}
-			logrus.Infof("setting dummy QoS resource %s=%s", r, c)
-
 		}
+	}
 
-		if c == "" {
-			return fmt.Errorf("empty class name not allowed for QoS resource type %q", r)
-		}
+	// Providers such as the cpuset QoS resource provider pick a class
+	// automatically, based on the pod's Kubernetes QoS class, for
+	// containers that didn't request one explicitly.
+	if err := qos.DefaultRegistry().ApplyPodQoSFallbacks(spec, containerID, sb.PodQoSClass(), requested); err != nil {
+		return err
 	}
 
 	// Handle RDT
@@ -116,9 +135,7 @@ func (s *Server) handleContainerQoSResources(spec *rspec.Spec, container *types.
 		return err
 	}
 	if rdtClass != "" {
-		logrus.Debugf("Setting RDT ClosID of container %s to %q", container.Metadata.Name, rdt.ResctrlPrefix+rdtClass)
-		// TODO: patch runtime-tools to support setting ClosID via a helper func similar to SetLinuxIntelRdtL3CacheSchema()
-		spec.Linux.IntelRdt = &rspec.LinuxIntelRdt{ClosID: rdt.ResctrlPrefix + rdtClass}
+		s.applyContainerRdtClass(spec, containerID, rdtClass)
 	}
 
 	// Handle BlockIO
@@ -127,17 +144,113 @@ func (s *Server) handleContainerQoSResources(spec *rspec.Spec, container *types.
 		return err
 	}
 	if blockioClass != "" {
-		if linuxBlockIO, err := blockio.OciLinuxBlockIO(blockioClass); err == nil {
-			if spec.Linux.Resources == nil {
-				spec.Linux.Resources = &rspec.LinuxResources{}
-			}
-			spec.Linux.Resources.BlockIO = linuxBlockIO
+		s.applyContainerBlockioClass(spec, containerID, blockioClass)
+	}
+
+	// Let NRI plugins observe and adjust the resolved spec before runc is
+	// invoked. Plugins only get to see the spec as it stood when we
+	// marshal it here; they can't react to their own adjustment.
+	ociSpec, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("marshal OCI spec for NRI CreateContainer: %w", err)
+	}
+	adj, err := nri.DefaultRegistry().Invoke(context.Background(), nri.EventCreateContainer, nri.Request{
+		PodSandboxID: sb.ID(),
+		ContainerID:  containerID,
+		QoSClasses:   requested,
+		OCISpec:      ociSpec,
+	})
+	if err != nil {
+		return fmt.Errorf("NRI CreateContainer: %w", err)
+	}
+	nri.Apply(spec, adj)
+
+	// nri.Apply only wrote adj's cpuset/annotation fields into spec; an
+	// RDT or BlockIO class a plugin overrode still needs to be re-resolved
+	// through their respective subsystems so the ClosID/cgroup values
+	// (and persisted class, monitoring group, ...) stay consistent with
+	// whatever ends up on the spec.
+	if adj.RDTClass != "" && adj.RDTClass != rdtClass {
+		if err := s.validateRdtClass(adj.RDTClass); err != nil {
+			return fmt.Errorf("NRI plugin requested RDT class %q: %w", adj.RDTClass, err)
 		}
+		s.applyContainerRdtClass(spec, containerID, adj.RDTClass)
+	}
+	if adj.BlockIOClass != "" && adj.BlockIOClass != blockioClass {
+		if err := s.validateBlockioClass(adj.BlockIOClass); err != nil {
+			return fmt.Errorf("NRI plugin requested BlockIO class %q: %w", adj.BlockIOClass, err)
+		}
+		s.applyContainerBlockioClass(spec, containerID, adj.BlockIOClass)
 	}
 
 	return nil
 }
 
+// applyContainerRdtClass sets containerID's ClosID to rdtClass on spec,
+// sets up its CMT/MBM monitoring group, and persists the class so a
+// CRI-O restart can reconcile it.
+func (s *Server) applyContainerRdtClass(spec *rspec.Spec, containerID, rdtClass string) {
+	logrus.Debugf("Setting RDT ClosID of container %s to %q", containerID, rdt.ResctrlPrefix+rdtClass)
+	// TODO: patch runtime-tools to support setting ClosID via a helper func similar to SetLinuxIntelRdtL3CacheSchema()
+	spec.Linux.IntelRdt = &rspec.LinuxIntelRdt{ClosID: rdt.ResctrlPrefix + rdtClass}
+
+	// Set up the CMT/MBM monitoring group now; its PID is attached by
+	// attachContainerRdtMonitoringPID once the runtime reports the
+	// container's PID. The StartContainer handler must call it; that
+	// wiring isn't part of this package.
+	if err := rdt.DefaultMonitor().EnsureMonGroup(containerID, rdtClass); err != nil {
+		logrus.Warnf("Failed to set up RDT monitoring group for container %s: %v", containerID, err)
+	}
+	if err := s.persistQoSClass(containerID, types.QoSResourceRdt, rdtClass); err != nil {
+		logrus.Warnf("Failed to persist RDT class for container %s: %v", containerID, err)
+	}
+}
+
+// applyContainerBlockioClass sets containerID's BlockIO cgroup values to
+// blockioClass on spec and persists the class so a CRI-O restart can
+// reconcile it.
+func (s *Server) applyContainerBlockioClass(spec *rspec.Spec, containerID, blockioClass string) {
+	if linuxBlockIO, err := blockio.OciLinuxBlockIO(blockioClass); err == nil {
+		if spec.Linux.Resources == nil {
+			spec.Linux.Resources = &rspec.LinuxResources{}
+		}
+		spec.Linux.Resources.BlockIO = linuxBlockIO
+	}
+	if err := s.persistQoSClass(containerID, types.QoSResourceBlockio, blockioClass); err != nil {
+		logrus.Warnf("Failed to persist BlockIO class for container %s: %v", containerID, err)
+	}
+}
+
+// validateRdtClass returns an error if class isn't one CRI-O's RDT config
+// knows about, or RDT is disabled.
+func (s *Server) validateRdtClass(class string) error {
+	crioRdt := s.Config().Rdt()
+	if !crioRdt.Enabled() {
+		return fmt.Errorf("RDT disabled")
+	}
+	for _, c := range crioRdt.GetClasses() {
+		if c == class {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown RDT class %q", class)
+}
+
+// validateBlockioClass returns an error if class isn't one CRI-O's
+// BlockIO config knows about, or BlockIO is disabled.
+func (s *Server) validateBlockioClass(class string) error {
+	crioBlockio := s.Config().BlockIO()
+	if !crioBlockio.Enabled() {
+		return fmt.Errorf("BlockIO disabled")
+	}
+	for _, c := range crioBlockio.GetClasses() {
+		if c == class {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown BlockIO class %q", class)
+}
+
 // getContainerRdtClass gets the effective RDT class of a container.
 func (s *Server) getContainerRdtClass(container *types.ContainerConfig, sb *sandbox.Sandbox) (string, error) {
 	crioRdt := s.Config().Rdt()
@@ -153,7 +266,7 @@ func (s *Server) getContainerRdtClass(container *types.ContainerConfig, sb *sand
 			return "", err
 		}
 		if cls != "" {
-			logrus.Debugf("RDT class %q from annotations (%s)", cls, ok, containerName)
+			logrus.Debugf("RDT class %q from annotations (%s)", cls, containerName)
 		}
 	}
 
@@ -179,7 +292,7 @@ func (s *Server) getContainerBlockioClass(container *types.ContainerConfig, sb *
 			return "", err
 		}
 		if cls != "" {
-			logrus.Debugf("BlockIO class %q from annotations (%s)", cls, ok, containerName)
+			logrus.Debugf("BlockIO class %q from annotations (%s)", cls, containerName)
 		}
 	}
 
@@ -190,55 +303,56 @@ func (s *Server) getContainerBlockioClass(container *types.ContainerConfig, sb *
 	return cls, nil
 }
 
+// releaseContainerQoSResources frees any per-container QoS state that
+// handleContainerQoSResources set up: exclusive cpuset allocations held
+// by providers such as the "cpu" provider's Allocator, the RDT
+// monitoring group, and the persisted class store entry. It must be
+// called once containerID is known to be gone, from the RemoveContainer
+// path; without it, exclusive cpuset allocations are never freed and
+// /var/lib/crio/qos-cpu.json grows until admission fails for every new
+// exclusive request.
+func (s *Server) releaseContainerQoSResources(containerID string) {
+	qos.DefaultRegistry().Release(containerID)
+	rdt.DefaultMonitor().RemoveContainer(containerID)
+
+	store, err := qos.DefaultClassStore()
+	if err != nil {
+		logrus.Warnf("Failed to open QoS class store to release container %s: %v", containerID, err)
+		return
+	}
+	if err := store.Remove(containerID); err != nil {
+		logrus.Warnf("Failed to remove QoS class store entry for container %s: %v", containerID, err)
+	}
+}
+
+// attachContainerRdtMonitoringPID finishes the RDT monitoring setup
+// handleContainerQoSResources started at create time by attaching pid
+// (the container's init process, once the runtime has started it and
+// reported its PID) to containerID's CMT/MBM monitoring group. It is a
+// no-op for a container that wasn't assigned an RDT class.
+//
+// CRI-O's StartContainer handler must call this once the runtime reports
+// the container's PID; that handler lives in server/container_start.go,
+// which is outside this source tree slice, so as things stand nothing
+// calls this in production and every monitoring group's tasks file stays
+// empty. Scoping that wiring into this request depends on that file
+// existing to edit.
+func (s *Server) attachContainerRdtMonitoringPID(containerID string, pid int) error {
+	monitor := rdt.DefaultMonitor()
+	if !monitor.HasGroup(containerID) {
+		return nil
+	}
+	if err := monitor.AttachPID(containerID, pid); err != nil {
+		return fmt.Errorf("attach RDT monitoring PID for container %s: %w", containerID, err)
+	}
+	return nil
+}
+
 func getClassFromResourceConfig(resourceType string, container *types.ContainerConfig, sb *sandbox.Sandbox) (string, bool) {
 	// Get class from container resources
 	cls, ok := container.GetQosResources().GetClasses()[resourceType]
 	if cls != "" && ok {
-		logrus.Debugf("%s class %q (%s) from container config (%s)", resourceType, cls, ok, containerName)
+		logrus.Debugf("%s class %q from container config (%s)", resourceType, cls, container.Metadata.Name)
 	}
 	return cls, ok
 }
-
-func init() {
-	// Initialize our dummy QoS resources hack
-	dummuGen := func(in []*types.QoSResourceInfo) map[string]map[string]struct{} {
-		out := make(map[string]map[string]struct{}, len(in))
-		for _, info := range in {
-			classes := make(map[string]struct{}, len(info.Classes))
-			for _, c := range info.Classes {
-				classes[c.Name] = struct{}{}
-			}
-			out[info.Name] = classes
-		}
-		return out
-	}
-
-	dummyPodQoSResourcesInfo = []*types.QoSResourceInfo{
-		&types.QoSResourceInfo{
-			Name:    "podres-1",
-			Mutable: false,
-			Classes: createClassInfos("qos-a", "qos-b", "qos-c", "qos-d"),
-		},
-		&types.QoSResourceInfo{
-			Name:    "podres-2",
-			Mutable: false,
-			Classes: createClassInfos("cls-1", "cls-2", "cls-3", "cls-4", "cls-5"),
-		},
-	}
-
-	dummyContainerQoSResourcesInfo = []*types.QoSResourceInfo{
-		&types.QoSResourceInfo{
-			Name:    "dummy-1",
-			Mutable: false,
-			Classes: createClassInfos("class-a", "class-b", "class-c", "class-d"),
-		},
-		&types.QoSResourceInfo{
-			Name:    "dummy-2",
-			Mutable: false,
-			Classes: createClassInfos("platinum", "gold", "silver", "bronze"),
-		},
-	}
-
-	dummyPodQoSResources = dummuGen(dummyPodQoSResourcesInfo)
-	dummyContainerQoSResources = dummuGen(dummyContainerQoSResourcesInfo)
-}
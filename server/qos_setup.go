@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/cri-o/cri-o/internal/config/qos"
+	"github.com/cri-o/cri-o/internal/config/qos/cpu"
+)
+
+// SetupQoSProviders loads the QoS provider subsystem for a new Server:
+// it registers the config-declared static providers and the topology-aware
+// CPU provider on qos.DefaultRegistry, then starts watching qosConfigPath
+// for SIGHUP reloads. cpuConfigPath may be empty, in which case the CPU
+// provider is not registered (e.g. the operator has no
+// `[crio.runtime.qos_cpu]` section configured).
+//
+// CRI-O's startup code must call this once while building a Server,
+// before the server starts accepting CRI requests; the returned stop func
+// should be called on shutdown.
+func SetupQoSProviders(qosConfigPath, cpuConfigPath string) (stop func(), err error) {
+	stop, err = qos.SetupFromConfig(qosConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if cpuConfigPath == "" {
+		return stop, nil
+	}
+
+	cpuCfg, err := cpu.LoadConfig(cpuConfigPath)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	if _, err := cpu.RegisterFromConfig(qos.DefaultRegistry(), cpuCfg); err != nil {
+		stop()
+		return nil, fmt.Errorf("register QoS CPU provider: %w", err)
+	}
+
+	return stop, nil
+}
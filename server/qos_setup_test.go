@@ -0,0 +1,91 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cri-o/cri-o/internal/config/qos"
+)
+
+// writeFakeSysfsCPU writes just enough of a sysfs cpu topology tree for
+// cpu.DiscoverTopology to succeed on a single CPU.
+func writeFakeSysfsCPU(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	topoDir := filepath.Join(dir, "cpu0", "topology")
+	if err := os.MkdirAll(topoDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range map[string]string{
+		"physical_package_id":  "0",
+		"core_id":              "0",
+		"thread_siblings_list": "0",
+	} {
+		if err := os.WriteFile(filepath.Join(topoDir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// TestSetupQoSProviders exercises SetupQoSProviders directly, since
+// nothing else in the tree calls it. Both scenarios run in one test
+// function, in this order, because qos.DefaultRegistry() is a
+// process-wide singleton a "cpu provider is absent" assertion can only
+// rely on before anything in the same test binary has registered one.
+func TestSetupQoSProviders(t *testing.T) {
+	t.Run("no cpu config path registers only static providers", func(t *testing.T) {
+		dir := t.TempDir()
+		qosConfigPath := filepath.Join(dir, "qos.toml")
+		if err := os.WriteFile(qosConfigPath, []byte(""), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		stop, err := SetupQoSProviders(qosConfigPath, "")
+		if err != nil {
+			t.Fatalf("SetupQoSProviders: %v", err)
+		}
+		defer stop()
+
+		if _, ok := qos.DefaultRegistry().Provider("cpu"); ok {
+			t.Fatal("expected no cpu provider registered when cpuConfigPath is empty")
+		}
+	})
+
+	t.Run("cpu config path registers the cpu provider alongside static providers", func(t *testing.T) {
+		dir := t.TempDir()
+
+		qosConfigPath := filepath.Join(dir, "qos.toml")
+		if err := os.WriteFile(qosConfigPath, []byte(`
+[provider.custom-1]
+classes = ["gold", "silver"]
+`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		sysfsRoot := writeFakeSysfsCPU(t)
+		statePath := filepath.Join(dir, "qos-cpu.json")
+		cpuConfigPath := filepath.Join(dir, "qos_cpu.toml")
+		if err := os.WriteFile(cpuConfigPath, []byte(`
+classes = { shared = "shared" }
+sysfs_root = "`+sysfsRoot+`"
+state_path = "`+statePath+`"
+`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		stop, err := SetupQoSProviders(qosConfigPath, cpuConfigPath)
+		if err != nil {
+			t.Fatalf("SetupQoSProviders: %v", err)
+		}
+		defer stop()
+
+		if _, ok := qos.DefaultRegistry().Provider("custom-1"); !ok {
+			t.Fatal("expected custom-1 static provider to be registered")
+		}
+		if _, ok := qos.DefaultRegistry().Provider("cpu"); !ok {
+			t.Fatal("expected the cpu provider to be registered")
+		}
+	})
+}
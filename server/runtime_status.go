@@ -1,8 +1,11 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
 
+	"github.com/cri-o/cri-o/internal/config/rdt"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 	types "k8s.io/cri-api/pkg/apis/runtime/v1"
 )
@@ -27,7 +30,7 @@ func (s *Server) Status(ctx context.Context, req *types.StatusRequest) (*types.S
 		networkCondition.Message = fmt.Sprintf("Network plugin returns error: %v", err)
 	}
 
-	return &types.StatusResponse{
+	resp := &types.StatusResponse{
 		Status: &types.RuntimeStatus{
 			Conditions: []*types.RuntimeCondition{
 				runtimeCondition,
@@ -38,5 +41,53 @@ func (s *Server) Status(ctx context.Context, req *types.StatusRequest) (*types.S
 				ContainerQosResources: s.getContainerQoSResourcesInfo(),
 			},
 		},
-	}, nil
+	}
+
+	if req.GetVerbose() {
+		info, err := rdtMonitoringInfo()
+		if err != nil {
+			logrus.Errorf("Failed to collect RDT monitoring info: %v", err)
+		} else {
+			resp.Info = map[string]string{"rdtMonitoring": info}
+		}
+	}
+
+	return resp, nil
+}
+
+// rdtMonitoringInfo JSON-encodes the latest CMT/MBM sample for every
+// container CRI-O currently monitors. It is surfaced in StatusResponse.Info
+// (populated only for verbose status requests) as a stand-in for a real
+// CRI extension field, until one lands upstream.
+func rdtMonitoringInfo() (string, error) {
+	monitor := rdt.DefaultMonitor()
+
+	type containerSample struct {
+		ContainerID       string `json:"containerId"`
+		Class             string `json:"class"`
+		LlcOccupancyBytes uint64 `json:"llcOccupancyBytes"`
+		MbmLocalBytes     uint64 `json:"mbmLocalBytes"`
+		MbmTotalBytes     uint64 `json:"mbmTotalBytes"`
+	}
+
+	samples := []containerSample{}
+	for _, id := range monitor.ContainerIDs() {
+		sample, class, ok := monitor.Sample(id)
+		if !ok {
+			continue
+		}
+		samples = append(samples, containerSample{
+			ContainerID:       id,
+			Class:             class,
+			LlcOccupancyBytes: sample.LLCOccupancyBytes,
+			MbmLocalBytes:     sample.MBMLocalBytes,
+			MbmTotalBytes:     sample.MBMTotalBytes,
+		})
+	}
+
+	b, err := json.Marshal(samples)
+	if err != nil {
+		return "", fmt.Errorf("marshal RDT monitoring info: %w", err)
+	}
+	return string(b), nil
 }